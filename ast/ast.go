@@ -1,11 +1,14 @@
+// Package ast declares the types used to represent the syntax tree of a MAML
+// document, along with constructors for building new nodes.
 package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strconv"
 	"strings"
 
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/token"
 )
 
 // Node is the base interface for all AST nodes.
@@ -14,6 +17,11 @@ type Node interface {
 	TokenLiteral() string
 	// String returns a string representation of the node.
 	String() string
+	// Pos returns the position of the first character of the node.
+	Pos() token.Position
+	// End returns the position immediately after the last character of the
+	// node.
+	End() token.Position
 }
 
 // Statement is a node that represents a statement.
@@ -42,6 +50,22 @@ func (p *Document) TokenLiteral() string {
 	return ""
 }
 
+// Pos returns the position of the first character of the root value.
+func (p *Document) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+// End returns the position immediately after the root value.
+func (p *Document) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 // String returns a string representation of the node.
 func (p *Document) String() string {
 	var out bytes.Buffer
@@ -59,6 +83,8 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return nodePos(es.Expression) }
+func (es *ExpressionStatement) End() token.Position  { return nodeEnd(es.Expression) }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -75,6 +101,8 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos() }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 // BooleanLiteral represents a boolean literal.
 type BooleanLiteral struct {
@@ -85,16 +113,29 @@ type BooleanLiteral struct {
 func (b *BooleanLiteral) expressionNode()      {}
 func (b *BooleanLiteral) TokenLiteral() string { return b.Token.Literal }
 func (b *BooleanLiteral) String() string       { return b.Token.Literal }
+func (b *BooleanLiteral) Pos() token.Position  { return b.Token.Pos() }
+func (b *BooleanLiteral) End() token.Position  { return b.Token.End }
 
 // IntegerLiteral represents an integer literal.
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // the value if it does not fit in an int64, otherwise nil
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos() }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
+
+// BigInt returns the value of the literal as a new big.Int.
+func (il *IntegerLiteral) BigInt() *big.Int {
+	if il.Big != nil {
+		return new(big.Int).Set(il.Big)
+	}
+	return big.NewInt(il.Value)
+}
 
 // FloatLiteral represents a float literal.
 type FloatLiteral struct {
@@ -105,6 +146,8 @@ type FloatLiteral struct {
 func (fl *FloatLiteral) expressionNode()      {}
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos() }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
 
 // StringLiteral represents a string literal.
 type StringLiteral struct {
@@ -115,15 +158,20 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return strconv.Quote(sl.Value) }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos() }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
 
 // ArrayLiteral represents an array literal.
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
-	Elements []Expression
+	Elements []*ArrayElement
+	EndToken token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos() }
+func (al *ArrayLiteral) End() token.Position  { return al.EndToken.End }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
@@ -136,14 +184,32 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// ArrayElement represents an element in an array literal, along with the
+// comments attached to it.
+type ArrayElement struct {
+	Value          Expression
+	HeadComments   []*Comment
+	LineComment    *Comment
+	FootComments   []*Comment
+	NewlinesBefore int // Used to track the number of newlines before the element
+}
+
+func (ae *ArrayElement) TokenLiteral() string { return ae.Value.TokenLiteral() }
+func (ae *ArrayElement) String() string       { return ae.Value.String() }
+func (ae *ArrayElement) Pos() token.Position  { return nodePos(ae.Value) }
+func (ae *ArrayElement) End() token.Position  { return nodeEnd(ae.Value) }
+
 // ObjectLiteral represents an object literal.
 type ObjectLiteral struct {
-	Token token.Token // the '{' token
-	Pairs []*KeyValueExpression
+	Token    token.Token // the '{' token
+	Pairs    []*KeyValueExpression
+	EndToken token.Token // the '}' token
 }
 
 func (ol *ObjectLiteral) expressionNode()      {}
 func (ol *ObjectLiteral) TokenLiteral() string { return ol.Token.Literal }
+func (ol *ObjectLiteral) Pos() token.Position  { return ol.Token.Pos() }
+func (ol *ObjectLiteral) End() token.Position  { return ol.EndToken.End }
 func (ol *ObjectLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
@@ -164,6 +230,8 @@ type Comment struct {
 
 func (c *Comment) TokenLiteral() string { return c.Token.Literal }
 func (c *Comment) String() string       { return c.Value }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos() }
+func (c *Comment) End() token.Position  { return c.Token.End }
 
 // KeyValueExpression represents a key-value pair in an object literal.
 type KeyValueExpression struct {
@@ -178,6 +246,8 @@ type KeyValueExpression struct {
 
 func (pe *KeyValueExpression) expressionNode()      {}
 func (pe *KeyValueExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *KeyValueExpression) Pos() token.Position  { return nodePos(pe.Key) }
+func (pe *KeyValueExpression) End() token.Position  { return nodeEnd(pe.Value) }
 func (pe *KeyValueExpression) String() string {
 	return pe.Key.String() + ":" + pe.Value.String()
 }
@@ -190,3 +260,47 @@ type NullLiteral struct {
 func (nl *NullLiteral) expressionNode()      {}
 func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
 func (nl *NullLiteral) String() string       { return "null" }
+func (nl *NullLiteral) Pos() token.Position  { return nl.Token.Pos() }
+func (nl *NullLiteral) End() token.Position  { return nl.Token.End }
+
+// BadExpression is a placeholder for source that could not be parsed. The
+// parser inserts it where a value was expected, so that the rest of the
+// document can still be inspected when it contains syntax errors.
+type BadExpression struct {
+	From token.Position // start of the invalid source
+	To   token.Position // position immediately after the invalid source
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return "" }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+func (be *BadExpression) Pos() token.Position  { return be.From }
+func (be *BadExpression) End() token.Position  { return be.To }
+
+// Root returns the root expression of the document, or nil if the document
+// is empty.
+func (p *Document) Root() Expression {
+	if len(p.Statements) == 0 {
+		return nil
+	}
+	if es, ok := p.Statements[0].(*ExpressionStatement); ok {
+		return es.Expression
+	}
+	return nil
+}
+
+// nodePos returns the start position of n, or the zero position if n is nil.
+func nodePos(n Node) token.Position {
+	if n == nil {
+		return token.Position{}
+	}
+	return n.Pos()
+}
+
+// nodeEnd returns the end position of n, or the zero position if n is nil.
+func nodeEnd(n Node) token.Position {
+	if n == nil {
+		return token.Position{}
+	}
+	return n.End()
+}
@@ -0,0 +1,191 @@
+package ast
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml/internal/lexer"
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+// NewDocument returns a document with expr as its root value.
+func NewDocument(expr Expression) *Document {
+	doc := &Document{Statements: []Statement{}}
+	if expr != nil {
+		doc.Statements = append(doc.Statements, &ExpressionStatement{
+			Token:      firstToken(expr),
+			Expression: expr,
+		})
+	}
+	return doc
+}
+
+// firstToken returns the token that starts expr.
+func firstToken(expr Expression) token.Token {
+	switch n := expr.(type) {
+	case *Identifier:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *FloatLiteral:
+		return n.Token
+	case *BooleanLiteral:
+		return n.Token
+	case *NullLiteral:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *ObjectLiteral:
+		return n.Token
+	}
+	return token.Token{}
+}
+
+// NewIdentifier returns an identifier node for name.
+func NewIdentifier(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+// NewString returns a string literal node for s.
+func NewString(s string) *StringLiteral {
+	return &StringLiteral{Token: token.Token{Type: token.STRING, Literal: s}, Value: s}
+}
+
+// NewInteger returns an integer literal node for v.
+func NewInteger(v int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(v, 10)}, Value: v}
+}
+
+// NewBigInteger returns an integer literal node for v, which may be outside
+// the range of an int64.
+func NewBigInteger(v *big.Int) *IntegerLiteral {
+	if v.IsInt64() {
+		return NewInteger(v.Int64())
+	}
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: v.String()}, Big: new(big.Int).Set(v)}
+}
+
+// NewFloat returns a float literal node for v. The literal always contains a
+// decimal point or an exponent so that it is read back as a float.
+func NewFloat(v float64) *FloatLiteral {
+	lit := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(lit, ".eE") {
+		lit += ".0"
+	}
+	return &FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: lit}, Value: v}
+}
+
+// NewBigFloat returns a float literal node for v, keeping all of its
+// precision in the literal. v must be finite.
+func NewBigFloat(v *big.Float) *FloatLiteral {
+	lit := v.Text('g', -1)
+	if !strings.ContainsAny(lit, ".eE") {
+		lit += ".0"
+	}
+	f, _ := v.Float64()
+	return &FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: lit}, Value: f}
+}
+
+// NewBoolean returns a boolean literal node for v.
+func NewBoolean(v bool) *BooleanLiteral {
+	if v {
+		return &BooleanLiteral{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true}
+	}
+	return &BooleanLiteral{Token: token.Token{Type: token.FALSE, Literal: "false"}, Value: false}
+}
+
+// NewNull returns a null literal node.
+func NewNull() *NullLiteral {
+	return &NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}
+}
+
+// NewArray returns an array literal node holding values.
+func NewArray(values ...Expression) *ArrayLiteral {
+	elements := make([]*ArrayElement, len(values))
+	for i, v := range values {
+		elements[i] = NewArrayElement(v)
+	}
+	return &ArrayLiteral{Token: token.Token{Type: token.LBRACK, Literal: "["}, Elements: elements}
+}
+
+// NewArrayElement returns an array element holding value.
+func NewArrayElement(value Expression) *ArrayElement {
+	return &ArrayElement{Value: value}
+}
+
+// NewObject returns an object literal node holding pairs.
+func NewObject(pairs ...*KeyValueExpression) *ObjectLiteral {
+	if pairs == nil {
+		pairs = []*KeyValueExpression{}
+	}
+	return &ObjectLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Pairs: pairs}
+}
+
+// NewKeyValue returns a key-value pair node. The key is built with NewKey.
+func NewKeyValue(key string, value Expression) *KeyValueExpression {
+	return &KeyValueExpression{
+		Token: token.Token{Type: token.COLON, Literal: ":"},
+		Key:   NewKey(key),
+		Value: value,
+	}
+}
+
+// NewKey returns a node suitable as an object key for name. Names that are
+// valid bare keys become an *Identifier; all others become a *StringLiteral.
+func NewKey(name string) Expression {
+	if !IsBareKey(name) {
+		return NewString(name)
+	}
+	tok := token.Token{Type: token.IDENT, Literal: name}
+	if typ, ok := lexer.ParseAsNumber(name); ok {
+		tok.Type = typ
+	}
+	return &Identifier{Token: tok, Value: name}
+}
+
+// NewComment returns a comment node with the given text. The text must not
+// contain the leading '#'.
+func NewComment(text string) *Comment {
+	return &Comment{Token: token.Token{Type: token.COMMENT, Literal: text}, Value: text}
+}
+
+// IsBareKey reports whether s can be written as an unquoted object key.
+// Bare keys can be identifiers or numbers, but not keywords.
+func IsBareKey(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	// Keywords must be quoted.
+	if token.LookupIdent(s) != token.IDENT {
+		return false
+	}
+
+	// If it can be parsed as a number, it can be a bare key.
+	if _, ok := lexer.ParseAsNumber(s); ok {
+		return true
+	}
+
+	// Otherwise, it must be a valid identifier.
+	// Must not start with a hyphen (unless it's a number, handled above).
+	if s[0] == '-' {
+		return false
+	}
+
+	for _, r := range s {
+		if !isIdentifierChar(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isIdentifierChar checks if a rune is a valid character for a MAML identifier.
+func isIdentifierChar(r rune) bool {
+	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
+		('0' <= r && r <= '9') || r == '_' || r == '-'
+}
@@ -0,0 +1,88 @@
+package ast
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KimNorgaard/go-maml/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKey(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType token.Type
+		isIdentifier bool
+	}{
+		{"name", token.IDENT, true},
+		{"my-key_2", token.IDENT, true},
+		{"42", token.INT, true},
+		{"1.5", token.FLOAT, true},
+		{"true", token.STRING, false},
+		{"null", token.STRING, false},
+		{"-dash", token.STRING, false},
+		{"has space", token.STRING, false},
+		{"", token.STRING, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			key := NewKey(tt.input)
+			if tt.isIdentifier {
+				ident, ok := key.(*Identifier)
+				require.True(t, ok, "expected *Identifier, got %T", key)
+				require.Equal(t, tt.expectedType, ident.Token.Type)
+				require.Equal(t, tt.input, ident.Value)
+			} else {
+				str, ok := key.(*StringLiteral)
+				require.True(t, ok, "expected *StringLiteral, got %T", key)
+				require.Equal(t, tt.input, str.Value)
+			}
+		})
+	}
+}
+
+func TestNewLiterals(t *testing.T) {
+	require.Equal(t, "3.0", NewFloat(3).TokenLiteral())
+	require.Equal(t, "0.25", NewFloat(0.25).TokenLiteral())
+	require.Equal(t, "-7", NewInteger(-7).TokenLiteral())
+	require.Equal(t, token.TRUE, NewBoolean(true).Token.Type)
+	require.Equal(t, token.FALSE, NewBoolean(false).Token.Type)
+	require.Equal(t, "null", NewNull().String())
+	require.Equal(t, "note", NewComment("note").Value)
+	require.NotNil(t, NewArray().Elements)
+	require.NotNil(t, NewObject().Pairs)
+}
+
+func TestNewBigLiterals(t *testing.T) {
+	small := NewBigInteger(big.NewInt(-7))
+	require.Nil(t, small.Big)
+	require.Equal(t, int64(-7), small.Value)
+
+	v, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	large := NewBigInteger(v)
+	require.Equal(t, "123456789012345678901234567890", large.TokenLiteral())
+	require.Zero(t, v.Cmp(large.BigInt()))
+	v.SetInt64(0)
+	require.Equal(t, "123456789012345678901234567890", large.Big.String(), "the value must be copied")
+
+	require.Equal(t, "3.0", NewBigFloat(big.NewFloat(3)).TokenLiteral())
+	require.Equal(t, "1e+100", NewBigFloat(new(big.Float).SetFloat64(1e100)).TokenLiteral())
+}
+
+func TestNewDocument(t *testing.T) {
+	obj := NewObject(
+		NewKeyValue("name", NewString("MAML")),
+		NewKeyValue("tags", NewArray(NewIdentifier("a"), NewInteger(1))),
+	)
+	doc := NewDocument(obj)
+
+	require.Len(t, doc.Statements, 1)
+	require.Same(t, obj, doc.Root())
+	require.Equal(t, token.LBRACE, doc.Statements[0].(*ExpressionStatement).Token.Type)
+	require.Equal(t, `{name:"MAML", tags:[a, 1]}`, doc.String())
+
+	empty := NewDocument(nil)
+	require.Empty(t, empty.Statements)
+	require.Nil(t, empty.Root())
+}
@@ -0,0 +1,460 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrPathNotFound is returned when a path does not resolve to a node in
+	// the document.
+	ErrPathNotFound = errors.New("path not found")
+
+	// ErrInvalidPath is returned when a path string cannot be parsed or
+	// cannot be applied to the node it addresses.
+	ErrInvalidPath = errors.New("invalid path")
+
+	// ErrKeyExists is returned when an edit would introduce a duplicate key
+	// in an object.
+	ErrKeyExists = errors.New("key already exists")
+)
+
+// PathSegment is a single step in a Path. It addresses either an object key
+// or an array index.
+type PathSegment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// Path is a parsed path into a MAML document.
+//
+// The textual form uses dots to separate object keys and brackets for array
+// indexes, e.g. `server.port` or `tags[2]`. Keys that contain dots, brackets
+// or other special characters can be written as quoted strings inside
+// brackets, e.g. `labels["app.kubernetes.io/name"]`. The empty path
+// addresses the root value of the document.
+type Path []PathSegment
+
+// ParsePath parses the textual form of a path.
+func ParsePath(s string) (Path, error) { //nolint:gocognit
+	path := Path{}
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("ast: %w %q: unterminated '['", ErrInvalidPath, s)
+			}
+			inner := s[i+1 : i+end]
+			if strings.HasPrefix(inner, `"`) {
+				key, rest, err := unquotePathKey(s[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("ast: %w %q: %w", ErrInvalidPath, s, err)
+				}
+				if !strings.HasPrefix(rest, "]") {
+					return nil, fmt.Errorf("ast: %w %q: expected ']' after quoted key", ErrInvalidPath, s)
+				}
+				path = append(path, PathSegment{Key: key})
+				i = len(s) - len(rest) + 1
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("ast: %w %q: invalid index %q", ErrInvalidPath, s, inner)
+			}
+			path = append(path, PathSegment{Index: idx, IsIndex: true})
+			i += end + 1
+		case '.':
+			if i == 0 || i == len(s)-1 {
+				return nil, fmt.Errorf("ast: %w %q: empty key", ErrInvalidPath, s)
+			}
+			i++
+			if s[i] == '.' || s[i] == '[' {
+				return nil, fmt.Errorf("ast: %w %q: empty key", ErrInvalidPath, s)
+			}
+		default:
+			if i > 0 && s[i-1] != '.' {
+				return nil, fmt.Errorf("ast: %w %q: expected '.' or '[' at offset %d", ErrInvalidPath, s, i)
+			}
+			end := strings.IndexAny(s[i:], ".[")
+			if end < 0 {
+				end = len(s) - i
+			}
+			path = append(path, PathSegment{Key: s[i : i+end]})
+			i += end
+		}
+	}
+	return path, nil
+}
+
+// unquotePathKey reads a double-quoted key from the start of s and returns
+// the unquoted key and the remainder of s.
+func unquotePathKey(s string) (key, rest string, err error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			key, err = strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted key %s", s[:i+1])
+			}
+			return key, s[i+1:], nil
+		}
+	}
+	return "", "", errors.New("unterminated quoted key")
+}
+
+// String returns the textual form of the path.
+func (p Path) String() string {
+	var b strings.Builder
+	for i, seg := range p {
+		switch {
+		case seg.IsIndex:
+			b.WriteString("[" + strconv.Itoa(seg.Index) + "]")
+		case isPlainPathKey(seg.Key):
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(seg.Key)
+		default:
+			b.WriteString("[" + strconv.Quote(seg.Key) + "]")
+		}
+	}
+	return b.String()
+}
+
+// isPlainPathKey reports whether key can be written in dotted form.
+func isPlainPathKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, `.[]"`)
+}
+
+// KeyString returns the key of the pair as a string, regardless of whether it
+// was written as an identifier or a quoted string.
+func (pe *KeyValueExpression) KeyString() string {
+	switch k := pe.Key.(type) {
+	case *Identifier:
+		return k.Value
+	case *StringLiteral:
+		return k.Value
+	}
+	return ""
+}
+
+// IndexOf returns the position of the pair with the given key, or -1 if the
+// object has no such key.
+func (ol *ObjectLiteral) IndexOf(key string) int {
+	for i, pair := range ol.Pairs {
+		if pair.KeyString() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the expression at path.
+func (p *Document) Get(path string) (Expression, error) {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	expr := p.Root()
+	if expr == nil {
+		return nil, pathError(path, ErrPathNotFound)
+	}
+	for i, seg := range parsed {
+		if expr, err = resolveSegment(expr, seg); err != nil {
+			return nil, pathError(parsed[:i+1].String(), err)
+		}
+	}
+	return expr, nil
+}
+
+// GetPair returns the key-value pair addressed by path, so that its key and
+// comments can be inspected or edited. The last segment of path must be an
+// object key.
+func (p *Document) GetPair(path string) (*KeyValueExpression, error) {
+	obj, i, err := p.lookupPair(path)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Pairs[i], nil
+}
+
+// GetElement returns the array element addressed by path, so that its
+// comments can be inspected or edited. The last segment of path must be an
+// array index.
+func (p *Document) GetElement(path string) (*ArrayElement, error) {
+	parent, seg, err := p.parent(path, false)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := parent.(*ArrayLiteral)
+	if !ok {
+		return nil, pathError(path, fmt.Errorf("%w: does not address an array element", ErrInvalidPath))
+	}
+	if seg.Index >= len(arr.Elements) {
+		return nil, pathError(path, ErrPathNotFound)
+	}
+	return arr.Elements[seg.Index], nil
+}
+
+// Set replaces the value at path with value.
+//
+// If the last segment of path names a key that does not exist, a new pair is
+// appended to the object. If it names the index one past the end of an
+// array, value is appended to the array. Missing intermediate objects are
+// created. When the value of an existing pair or array element is replaced,
+// it keeps its key, comments and spacing.
+func (p *Document) Set(path string, value Expression) error {
+	if value == nil {
+		return pathError(path, errors.New("cannot set nil value"))
+	}
+	if path == "" {
+		p.setRoot(value)
+		return nil
+	}
+	parent, seg, err := p.parent(path, true)
+	if err != nil {
+		return err
+	}
+	switch container := parent.(type) {
+	case *ObjectLiteral:
+		if i := container.IndexOf(seg.Key); i >= 0 {
+			container.Pairs[i].Value = value
+			return nil
+		}
+		container.Pairs = append(container.Pairs, NewKeyValue(seg.Key, value))
+	case *ArrayLiteral:
+		switch {
+		case seg.Index < len(container.Elements):
+			container.Elements[seg.Index].Value = value
+		case seg.Index == len(container.Elements):
+			container.Elements = append(container.Elements, NewArrayElement(value))
+		default:
+			return pathError(path, ErrPathNotFound)
+		}
+	}
+	return nil
+}
+
+// Insert adds value at path without replacing an existing value.
+//
+// If the last segment of path is an array index, value is inserted before the
+// element currently at that index; an index equal to the array length
+// appends. If the last segment is an object key, a new pair is appended and
+// ErrKeyExists is returned if the key is already present.
+func (p *Document) Insert(path string, value Expression) error {
+	if value == nil {
+		return pathError(path, errors.New("cannot insert nil value"))
+	}
+	parent, seg, err := p.parent(path, true)
+	if err != nil {
+		return err
+	}
+	switch container := parent.(type) {
+	case *ObjectLiteral:
+		if container.IndexOf(seg.Key) >= 0 {
+			return pathError(path, ErrKeyExists)
+		}
+		container.Pairs = append(container.Pairs, NewKeyValue(seg.Key, value))
+	case *ArrayLiteral:
+		if seg.Index > len(container.Elements) {
+			return pathError(path, ErrPathNotFound)
+		}
+		container.Elements = slices.Insert(container.Elements, seg.Index, NewArrayElement(value))
+	}
+	return nil
+}
+
+// Delete removes the pair or array element at path.
+//
+// When a pair or element is removed, a blank line that preceded it is carried
+// over to the following one, so that groups separated by blank lines stay
+// separated.
+func (p *Document) Delete(path string) error {
+	parent, seg, err := p.parent(path, false)
+	if err != nil {
+		return err
+	}
+	switch container := parent.(type) {
+	case *ObjectLiteral:
+		i := container.IndexOf(seg.Key)
+		if i < 0 {
+			return pathError(path, ErrPathNotFound)
+		}
+		if i+1 < len(container.Pairs) {
+			next := container.Pairs[i+1]
+			next.NewlinesBefore = max(next.NewlinesBefore, container.Pairs[i].NewlinesBefore)
+		}
+		container.Pairs = slices.Delete(container.Pairs, i, i+1)
+	case *ArrayLiteral:
+		i := seg.Index
+		if i >= len(container.Elements) {
+			return pathError(path, ErrPathNotFound)
+		}
+		if i+1 < len(container.Elements) {
+			next := container.Elements[i+1]
+			next.NewlinesBefore = max(next.NewlinesBefore, container.Elements[i].NewlinesBefore)
+		}
+		container.Elements = slices.Delete(container.Elements, i, i+1)
+	}
+	return nil
+}
+
+// Rename changes the key of the pair at path to newKey. The pair keeps its
+// value, comments and position in the object.
+func (p *Document) Rename(path, newKey string) error {
+	obj, i, err := p.lookupPair(path)
+	if err != nil {
+		return err
+	}
+	if j := obj.IndexOf(newKey); j >= 0 && j != i {
+		return pathError(path, fmt.Errorf("%w: %s", ErrKeyExists, newKey))
+	}
+	obj.Pairs[i].Key = NewKey(newKey)
+	return nil
+}
+
+// lookupPair resolves path to an object and the position of the addressed
+// pair within it.
+func (p *Document) lookupPair(path string) (*ObjectLiteral, int, error) {
+	parent, seg, err := p.parent(path, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	obj, ok := parent.(*ObjectLiteral)
+	if !ok {
+		return nil, 0, pathError(path, fmt.Errorf("%w: does not address an object key", ErrInvalidPath))
+	}
+	i := obj.IndexOf(seg.Key)
+	if i < 0 {
+		return nil, 0, pathError(path, ErrPathNotFound)
+	}
+	return obj, i, nil
+}
+
+// parent resolves all but the last segment of path and returns the container
+// expression together with the last segment. The last segment is checked to
+// match the kind of the container. If create is true, missing intermediate
+// object keys are created as empty objects.
+func (p *Document) parent(path string, create bool) (Expression, PathSegment, error) {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		return nil, PathSegment{}, err
+	}
+	if len(parsed) == 0 {
+		return nil, PathSegment{}, fmt.Errorf("ast: %w: the root value has no parent", ErrInvalidPath)
+	}
+	current := p.Root()
+	if current == nil {
+		if !create || parsed[0].IsIndex {
+			return nil, PathSegment{}, pathError(path, ErrPathNotFound)
+		}
+		current = NewObject()
+		p.setRoot(current)
+	}
+
+	for i, seg := range parsed[:len(parsed)-1] {
+		next, err := resolveSegment(current, seg)
+		if errors.Is(err, ErrPathNotFound) && create && !seg.IsIndex {
+			next = NewObject()
+			obj, _ := current.(*ObjectLiteral) //nolint:errcheck // only objects report a missing key.
+			obj.Pairs = append(obj.Pairs, NewKeyValue(seg.Key, next))
+		} else if err != nil {
+			return nil, PathSegment{}, pathError(parsed[:i+1].String(), err)
+		}
+		current = next
+	}
+
+	last := parsed[len(parsed)-1]
+	if err := checkSegment(current, last); err != nil {
+		return nil, PathSegment{}, pathError(path, err)
+	}
+	return current, last, nil
+}
+
+// setRoot replaces the root value of the document, keeping its head comments.
+func (p *Document) setRoot(value Expression) {
+	if len(p.Statements) > 0 {
+		if es, ok := p.Statements[0].(*ExpressionStatement); ok {
+			es.Expression = value
+			es.Token = firstToken(value)
+			return
+		}
+	}
+	p.Statements = NewDocument(value).Statements
+}
+
+// checkSegment reports whether seg can be applied to expr.
+func checkSegment(expr Expression, seg PathSegment) error {
+	switch expr.(type) {
+	case *ObjectLiteral:
+		if seg.IsIndex {
+			return fmt.Errorf("%w: cannot index object with [%d]", ErrInvalidPath, seg.Index)
+		}
+	case *ArrayLiteral:
+		if !seg.IsIndex {
+			return fmt.Errorf("%w: cannot use key %q on array", ErrInvalidPath, seg.Key)
+		}
+	default:
+		return fmt.Errorf("%w: %s is not an object or array", ErrInvalidPath, nodeKind(expr))
+	}
+	return nil
+}
+
+// resolveSegment returns the child of expr addressed by seg.
+func resolveSegment(expr Expression, seg PathSegment) (Expression, error) {
+	if err := checkSegment(expr, seg); err != nil {
+		return nil, err
+	}
+	switch container := expr.(type) {
+	case *ObjectLiteral:
+		if i := container.IndexOf(seg.Key); i >= 0 {
+			return container.Pairs[i].Value, nil
+		}
+	case *ArrayLiteral:
+		if seg.Index < len(container.Elements) {
+			return container.Elements[seg.Index].Value, nil
+		}
+	}
+	return nil, ErrPathNotFound
+}
+
+// nodeKind returns a short, human-readable name for the kind of expr.
+func nodeKind(expr Expression) string {
+	switch expr.(type) {
+	case *ObjectLiteral:
+		return "object"
+	case *ArrayLiteral:
+		return "array"
+	case *StringLiteral:
+		return "string"
+	case *Identifier:
+		return "identifier"
+	case *IntegerLiteral:
+		return "integer"
+	case *FloatLiteral:
+		return "float"
+	case *BooleanLiteral:
+		return "boolean"
+	case *NullLiteral:
+		return "null"
+	case *BadExpression:
+		return "bad expression"
+	}
+	return fmt.Sprintf("%T", expr)
+}
+
+// pathError annotates err with the path it occurred at.
+func pathError(path string, err error) error {
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Errorf("ast: %s: %w", path, err)
+}
@@ -0,0 +1,235 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/stretchr/testify/require"
+)
+
+const pathTestInput = `# Service configuration
+{
+  # Network settings
+  server: {
+    host: "localhost" # bind address
+    port: 8080
+  }
+
+  # Deployment tags
+  tags: ["a", "b", "c"]
+  "app.name": "demo"
+}`
+
+func parseDoc(t *testing.T, src string) *ast.Document {
+	t.Helper()
+	doc, err := maml.Parse([]byte(src))
+	require.NoError(t, err)
+	return doc
+}
+
+func format(t *testing.T, doc *ast.Document) string {
+	t.Helper()
+	out, err := maml.Marshal(doc, maml.Indent(2))
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ast.Path
+	}{
+		{"", ast.Path{}},
+		{"server", ast.Path{{Key: "server"}}},
+		{"server.port", ast.Path{{Key: "server"}, {Key: "port"}}},
+		{"tags[2]", ast.Path{{Key: "tags"}, {Index: 2, IsIndex: true}}},
+		{"[0][1].x", ast.Path{{Index: 0, IsIndex: true}, {Index: 1, IsIndex: true}, {Key: "x"}}},
+		{`labels["app.kubernetes.io/name"]`, ast.Path{{Key: "labels"}, {Key: "app.kubernetes.io/name"}}},
+		{`["a]b"].c`, ast.Path{{Key: "a]b"}, {Key: "c"}}},
+		{"ports.8080", ast.Path{{Key: "ports"}, {Key: "8080"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			path, err := ast.ParsePath(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, path)
+
+			reparsed, err := ast.ParsePath(path.String())
+			require.NoError(t, err)
+			require.Equal(t, path, reparsed, "String() must round-trip")
+		})
+	}
+
+	invalid := []string{".a", "a.", "a..b", "a[", "a[x]", "a[-1]", "a[0]b", `a["x`, `a["x"`, "a.[0]"}
+	for _, input := range invalid {
+		t.Run("invalid "+input, func(t *testing.T) {
+			_, err := ast.ParsePath(input)
+			require.ErrorIs(t, err, ast.ErrInvalidPath)
+		})
+	}
+}
+
+func TestDocument_Get(t *testing.T) {
+	doc := parseDoc(t, pathTestInput)
+
+	port, err := doc.Get("server.port")
+	require.NoError(t, err)
+	require.Equal(t, int64(8080), port.(*ast.IntegerLiteral).Value)
+
+	tag, err := doc.Get("tags[1]")
+	require.NoError(t, err)
+	require.Equal(t, "b", tag.(*ast.StringLiteral).Value)
+
+	name, err := doc.Get(`["app.name"]`)
+	require.NoError(t, err)
+	require.Equal(t, "demo", name.(*ast.StringLiteral).Value)
+
+	root, err := doc.Get("")
+	require.NoError(t, err)
+	require.Same(t, doc.Root(), root)
+
+	_, err = doc.Get("server.missing")
+	require.ErrorIs(t, err, ast.ErrPathNotFound)
+	require.EqualError(t, err, "ast: server.missing: path not found")
+
+	_, err = doc.Get("tags[3]")
+	require.ErrorIs(t, err, ast.ErrPathNotFound)
+
+	_, err = doc.Get("server.port.x")
+	require.ErrorIs(t, err, ast.ErrInvalidPath)
+	require.Contains(t, err.Error(), "integer is not an object or array")
+
+	_, err = doc.Get("tags.x")
+	require.ErrorIs(t, err, ast.ErrInvalidPath)
+
+	_, err = ast.NewDocument(nil).Get("a")
+	require.ErrorIs(t, err, ast.ErrPathNotFound)
+}
+
+func TestDocument_Set(t *testing.T) {
+	t.Run("Replace keeps comments", func(t *testing.T) {
+		doc := parseDoc(t, pathTestInput)
+		require.NoError(t, doc.Set("server.host", ast.NewString("0.0.0.0")))
+		require.NoError(t, doc.Set("tags[0]", ast.NewString("x")))
+
+		out := format(t, doc)
+		require.Contains(t, out, "  # Network settings\n  server: {\n    host: \"0.0.0.0\" # bind address\n")
+		require.Contains(t, out, "\n\n  # Deployment tags\n  tags: [\n    \"x\"")
+	})
+
+	t.Run("Insert missing keys and intermediate objects", func(t *testing.T) {
+		doc := parseDoc(t, "{\n  a: 1\n}")
+		require.NoError(t, doc.Set("b.c.d", ast.NewBoolean(true)))
+		require.Equal(t, "{\n  a: 1\n  b: {\n    c: {\n      d: true\n    }\n  }\n}", format(t, doc))
+	})
+
+	t.Run("Append to array", func(t *testing.T) {
+		doc := parseDoc(t, pathTestInput)
+		require.NoError(t, doc.Set("tags[3]", ast.NewString("d")))
+		tags, err := doc.Get("tags")
+		require.NoError(t, err)
+		require.Len(t, tags.(*ast.ArrayLiteral).Elements, 4)
+
+		err = doc.Set("tags[9]", ast.NewString("z"))
+		require.ErrorIs(t, err, ast.ErrPathNotFound)
+	})
+
+	t.Run("Replace root and fill empty document", func(t *testing.T) {
+		doc := parseDoc(t, "# head\n[1]")
+		require.NoError(t, doc.Set("", ast.NewObject()))
+		require.Equal(t, "# head\n{}", format(t, doc))
+
+		empty := ast.NewDocument(nil)
+		require.NoError(t, empty.Set("name", ast.NewString("x")))
+		require.Equal(t, "{\n  name: \"x\"\n}", format(t, empty))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		doc := parseDoc(t, pathTestInput)
+		require.ErrorIs(t, doc.Set("server[0]", ast.NewNull()), ast.ErrInvalidPath)
+		require.ErrorIs(t, doc.Set("tags.x", ast.NewNull()), ast.ErrInvalidPath)
+		require.ErrorIs(t, doc.Set("server.port.x", ast.NewNull()), ast.ErrInvalidPath)
+		require.Error(t, doc.Set("server.port", nil))
+	})
+}
+
+func TestDocument_Insert(t *testing.T) {
+	doc := parseDoc(t, pathTestInput)
+	require.NoError(t, doc.Insert("tags[1]", ast.NewString("between")))
+	require.NoError(t, doc.Insert("tags[4]", ast.NewString("end")))
+
+	tags, err := doc.Get("tags")
+	require.NoError(t, err)
+	require.Equal(t, `["a", "between", "b", "c", "end"]`, tags.String())
+
+	require.ErrorIs(t, doc.Insert("tags[9]", ast.NewNull()), ast.ErrPathNotFound)
+	require.ErrorIs(t, doc.Insert("server.port", ast.NewNull()), ast.ErrKeyExists)
+	require.NoError(t, doc.Insert("server.tls", ast.NewBoolean(false)))
+}
+
+func TestDocument_Delete(t *testing.T) {
+	t.Run("Delete pair keeps neighbours and blank lines", func(t *testing.T) {
+		doc := parseDoc(t, "{\n  a: 1\n\n  # about b\n  b: 2\n  c: 3\n}")
+		require.NoError(t, doc.Delete("b"))
+		require.Equal(t, "{\n  a: 1\n\n  c: 3\n}", format(t, doc))
+	})
+
+	t.Run("Delete array element", func(t *testing.T) {
+		doc := parseDoc(t, pathTestInput)
+		require.NoError(t, doc.Delete("tags[0]"))
+		tags, err := doc.Get("tags")
+		require.NoError(t, err)
+		require.Equal(t, `["b", "c"]`, tags.String())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		doc := parseDoc(t, pathTestInput)
+		require.ErrorIs(t, doc.Delete("server.missing"), ast.ErrPathNotFound)
+		require.ErrorIs(t, doc.Delete("missing.key"), ast.ErrPathNotFound)
+		require.ErrorIs(t, doc.Delete("tags[3]"), ast.ErrPathNotFound)
+		require.ErrorIs(t, doc.Delete(""), ast.ErrInvalidPath)
+	})
+}
+
+func TestDocument_Rename(t *testing.T) {
+	doc := parseDoc(t, pathTestInput)
+	require.NoError(t, doc.Rename("server.host", "bind address"))
+	require.NoError(t, doc.Rename("server.port", "port"))
+
+	out := format(t, doc)
+	require.Contains(t, out, "    \"bind address\": \"localhost\" # bind address\n    port: 8080\n")
+
+	require.ErrorIs(t, doc.Rename("server.port", "bind address"), ast.ErrKeyExists)
+	require.ErrorIs(t, doc.Rename("tags[0]", "x"), ast.ErrInvalidPath)
+	require.ErrorIs(t, doc.Rename("server.nope", "x"), ast.ErrPathNotFound)
+}
+
+func TestDocument_GetPair(t *testing.T) {
+	doc := parseDoc(t, pathTestInput)
+	pair, err := doc.GetPair("server")
+	require.NoError(t, err)
+	require.Equal(t, "Network settings", pair.HeadComments[0].Value)
+
+	pair.HeadComments = append(pair.HeadComments, ast.NewComment("Edited"))
+	require.Contains(t, format(t, doc), "  # Network settings\n  # Edited\n  server: {")
+}
+
+func TestDocument_ArrayElementComments(t *testing.T) {
+	src := "{\n  hosts: [\n    # staging host\n    \"a\" # first\n\n    \"b\"\n    \"c\"\n  ]\n}"
+	doc := parseDoc(t, src)
+
+	require.NoError(t, doc.Set("hosts[0]", ast.NewString("staging.example.com")))
+	require.NoError(t, doc.Delete("hosts[1]"))
+	elem, err := doc.GetElement("hosts[1]")
+	require.NoError(t, err)
+	elem.LineComment = ast.NewComment("last")
+
+	expected := "{\n  hosts: [\n    # staging host\n    \"staging.example.com\" # first\n\n    \"c\" # last\n  ]\n}"
+	require.Equal(t, expected, format(t, doc))
+
+	_, err = doc.GetElement("hosts[5]")
+	require.ErrorIs(t, err, ast.ErrPathNotFound)
+	_, err = doc.GetElement("hosts")
+	require.ErrorIs(t, err, ast.ErrInvalidPath)
+}
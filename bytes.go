@@ -0,0 +1,117 @@
+package maml
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml/ast"
+)
+
+// byteEncoding is the string encoding of a []byte or [N]byte value. It is
+// selected with a struct tag option, e.g. `maml:"digest,hex"`.
+type byteEncoding int
+
+const (
+	bytesBase64    byteEncoding = iota // standard base64 with padding, the default
+	bytesBase64URL                     // "base64url": URL-safe base64 without padding
+	bytesHex                           // "hex": lowercase hexadecimal
+	bytesText                          // "text": the bytes as is, e.g. for PEM blocks
+)
+
+// tagByteEncoding returns the byte encoding selected by the options of a
+// maml struct tag.
+func tagByteEncoding(opts map[string]bool) byteEncoding {
+	switch {
+	case opts["hex"]:
+		return bytesHex
+	case opts["base64url"]:
+		return bytesBase64URL
+	case opts["text"]:
+		return bytesText
+	default:
+		return bytesBase64
+	}
+}
+
+// isByteSequence reports whether t is a slice or array of bytes, which
+// encodes as a string.
+func isByteSequence(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// byteSequence returns the bytes of a slice or array of bytes.
+func byteSequence(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	return b
+}
+
+// marshalBytes encodes a slice or array of bytes as a string, in the encoding
+// selected by the struct tag of the field being encoded. Text containing
+// newlines, such as a PEM block, is written as a multiline string.
+func (e *encodeState) marshalBytes(v reflect.Value) (ast.Node, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return ast.NewNull(), nil
+	}
+	b := byteSequence(v)
+	switch e.bytes {
+	case bytesBase64URL:
+		return ast.NewString(base64.RawURLEncoding.EncodeToString(b)), nil
+	case bytesHex:
+		return ast.NewString(hex.EncodeToString(b)), nil
+	case bytesText:
+		return ast.NewString(string(b)), nil
+	default:
+		return ast.NewString(base64.StdEncoding.EncodeToString(b)), nil
+	}
+}
+
+// mapBytes decodes a string into a slice or array of bytes. Whitespace is
+// ignored for the binary encodings, so that long values can be wrapped in a
+// multiline string. An array must receive exactly as many bytes as its
+// length.
+func (ds *decodeState) mapBytes(s *ast.StringLiteral, rv reflect.Value) error {
+	text, err := ds.stringValue(s)
+	if err != nil {
+		return ds.report(err)
+	}
+	var b []byte
+	switch ds.bytes {
+	case bytesText:
+		b = []byte(text)
+	case bytesHex:
+		b, err = hex.DecodeString(stripSpace(text))
+	case bytesBase64URL:
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(stripSpace(text), "="))
+	default:
+		b, err = base64.StdEncoding.DecodeString(stripSpace(text))
+	}
+	if err != nil {
+		return ds.parseError("string", s, rv.Type(), err)
+	}
+
+	if rv.Kind() == reflect.Array {
+		if len(b) != rv.Len() {
+			return ds.parseError("string", s, rv.Type(), fmt.Errorf("expected %d bytes, got %d", rv.Len(), len(b)))
+		}
+		for i, c := range b {
+			rv.Index(i).SetUint(uint64(c))
+		}
+		return nil
+	}
+	rv.SetBytes(b)
+	return nil
+}
+
+// stripSpace removes all whitespace from s.
+func stripSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
@@ -0,0 +1,94 @@
+package maml_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/stretchr/testify/require"
+)
+
+const testPEM = `-----BEGIN CERTIFICATE-----
+MIIBszCCAVmgAwIBAgIUZ2V0LW1hbWwtdGVzdC1jZXJ0aWZpY2F0ZTAKBggqhkjO
+-----END CERTIFICATE-----
+`
+
+func TestBytes(t *testing.T) {
+	type keys struct {
+		Key    []byte   `maml:"key"`
+		Digest [4]byte  `maml:"digest,hex"`
+		Token  []byte   `maml:"token,base64url"`
+		Hashes [][]byte `maml:"hashes,hex"`
+		Empty  []byte   `maml:"empty"`
+		Nil    []byte   `maml:"nil"`
+	}
+	in := keys{
+		Key:    []byte("hello, world"),
+		Digest: [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Token:  []byte{0xfb, 0xff, 0xfe},
+		Hashes: [][]byte{{0x01}, {0x02, 0x03}},
+		Empty:  []byte{},
+	}
+
+	b, err := maml.Marshal(in, maml.Indent(0))
+	require.NoError(t, err)
+	require.Equal(t, `{key:"aGVsbG8sIHdvcmxk",digest:"deadbeef",token:"-__-",hashes:["01","0203"],empty:"",nil:null}`, string(b))
+
+	var out keys
+	require.NoError(t, maml.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+}
+
+func TestBytes_Text(t *testing.T) {
+	type tls struct {
+		Cert []byte `maml:"cert,text"`
+	}
+
+	b, err := maml.Marshal(tls{Cert: []byte(testPEM)})
+	require.NoError(t, err)
+	require.Equal(t, "{\n  cert: \"\"\"\n"+testPEM+"\"\"\"\n}", string(b))
+
+	var out tls
+	require.NoError(t, maml.Unmarshal(b, &out))
+	require.Equal(t, testPEM, string(out.Cert))
+}
+
+func TestBytes_Decode(t *testing.T) {
+	t.Run("Wrapped base64 in a multiline string", func(t *testing.T) {
+		var v struct{ Key []byte }
+		require.NoError(t, maml.Unmarshal([]byte("{Key: \"\"\"\n  aGVsbG8s\n  IHdvcmxk\n\"\"\"}"), &v))
+		require.Equal(t, "hello, world", string(v.Key))
+	})
+
+	t.Run("Padded base64url", func(t *testing.T) {
+		var v struct {
+			Token []byte `maml:",base64url"`
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{Token: "-_8="}`), &v))
+		require.Equal(t, []byte{0xfb, 0xff}, v.Token)
+	})
+
+	t.Run("Array of integers", func(t *testing.T) {
+		var v []byte
+		require.NoError(t, maml.Unmarshal([]byte(`[1, 2, 3]`), &v))
+		require.Equal(t, []byte{1, 2, 3}, v)
+	})
+
+	t.Run("Invalid base64", func(t *testing.T) {
+		var v struct{ Key []byte }
+		err := maml.Unmarshal([]byte(`{Key: "not base64!"}`), &v)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "maml: cannot unmarshal string into Go value of type []uint8: illegal base64 data")
+	})
+
+	t.Run("Wrong array length", func(t *testing.T) {
+		var v struct {
+			Digest [4]byte `maml:",hex"`
+		}
+		err := maml.Unmarshal([]byte(`{Digest: "dead"}`), &v)
+		require.EqualError(t, err, "maml: cannot unmarshal string into Go value of type [4]uint8: expected 4 bytes, got 2 (Digest at line 1, column 10)")
+		var valueErr *maml.InvalidValueError
+		require.ErrorAs(t, err, &valueErr)
+		require.Equal(t, reflect.TypeFor[[4]byte](), valueErr.Type)
+	})
+}
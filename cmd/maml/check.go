@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/KimNorgaard/go-maml"
+	mamlerrors "github.com/KimNorgaard/go-maml/errors"
+)
+
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	verbose := fs.Bool("v", false, "show the offending source line for each error")
+	color := fs.Bool("color", false, "colour the output of -v with ANSI escape sequences")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: maml check [-v [-color]] [path ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	files := []string{stdinName}
+	if fs.NArg() > 0 {
+		var err error
+		if files, err = expandPaths(fs.Args()); err != nil {
+			fmt.Fprintf(stderr, "maml check: %v\n", err)
+			return exitError
+		}
+	}
+
+	code := exitOK
+	for _, name := range files {
+		src, err := readInput(name, stdin)
+		if err != nil {
+			reportError(stdout, name, err)
+			code = exitError
+			continue
+		}
+		if _, err := maml.Parse(src); err != nil {
+			if *verbose {
+				r := &mamlerrors.Renderer{Filename: name, Color: *color}
+				_ = r.Render(stdout, src, err)
+			} else {
+				reportError(stdout, name, err)
+			}
+			code = exitError
+		}
+	}
+	return code
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml"
+)
+
+// Formats understood by the convert command.
+const (
+	formatMAML = "maml"
+	formatJSON = "json"
+)
+
+func runConvert(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var (
+		to     string
+		indent int
+	)
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: maml convert [flags] [file]")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&to, "to", "", "output format, maml or json (default: inferred from the input file extension)")
+	fs.IntVar(&indent, "indent", 2, "number of spaces per indentation level")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	name := stdinName
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+	if to == "" {
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".json":
+			to = formatMAML
+		case ".maml":
+			to = formatJSON
+		default:
+			fmt.Fprintln(stderr, "maml convert: cannot infer output format, use -to maml or -to json")
+			return exitUsage
+		}
+	}
+	if to != formatMAML && to != formatJSON {
+		fmt.Fprintf(stderr, "maml convert: unknown format %q\n", to)
+		return exitUsage
+	}
+
+	src, err := readInput(name, stdin)
+	if err != nil {
+		reportError(stderr, name, err)
+		return exitError
+	}
+	var out []byte
+	if to == formatJSON {
+		out, err = mamlToJSON(src, indent)
+	} else {
+		out, err = jsonToMAML(src, indent)
+	}
+	if err != nil {
+		reportError(stderr, name, err)
+		return exitError
+	}
+	if _, err := stdout.Write(out); err != nil {
+		fmt.Fprintf(stderr, "maml convert: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// mamlToJSON converts a MAML document to JSON.
+func mamlToJSON(src []byte, indent int) ([]byte, error) {
+	var v any
+	if err := maml.Unmarshal(src, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", strings.Repeat(" ", indent))
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonToMAML converts a JSON document to MAML.
+func jsonToMAML(src []byte, indent int) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after top-level JSON value")
+	}
+	v, err := fromJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	out, err := maml.Marshal(v, maml.Indent(indent))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// fromJSON replaces the json.Number values produced by a UseNumber decoder
+// with int64, *big.Int or float64 values, so that integers stay integers in
+// MAML. It fails on numbers that are out of range for a float64, which MAML
+// cannot represent.
+func fromJSON(v any) (any, error) {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		if i, ok := new(big.Int).SetString(val.String(), 10); ok {
+			return i, nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s: %w", val, err)
+		}
+		return f, nil
+	case []any:
+		for i, elem := range val {
+			elem, err := fromJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			val[i] = elem
+		}
+		return val, nil
+	case map[string]any:
+		for k, elem := range val {
+			elem, err := fromJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			val[k] = elem
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type editOp int
+
+const (
+	opEqual editOp = iota
+	opDelete
+	opInsert
+)
+
+// edit is a single line of an edit script.
+type edit struct {
+	op   editOp
+	line string
+}
+
+// unifiedDiff returns a unified diff that turns a into b. It returns the
+// empty string if a and b are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for _, h := range hunks(edits) {
+		writeHunk(&out, edits, h)
+	}
+	return out.String()
+}
+
+// splitLines splits s into lines, keeping the line terminators.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script from a to b using Myers'
+// algorithm.
+func diffLines(a, b []string) []edit { //nolint:gocognit
+	// Strip the common prefix and suffix; they are the common case for
+	// formatting changes and keep the search space small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	edits = append(edits, myers(ma, mb)...)
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{opEqual, line})
+	}
+	return edits
+}
+
+// myers returns the edit script for a and b. The furthest-reaching x for each
+// diagonal k is recorded per round, so that the path can be traced back.
+func myers(a, b []string) []edit { //nolint:gocognit
+	n, m := len(a), len(b)
+	maxD := n + m
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the recorded rounds from the end of both inputs back to
+// the start and returns the edits in forward order.
+func backtrack(a, b []string, trace [][]int) []edit {
+	var edits []edit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d] holds diagonals -d-1 .. d+1 as they were before round d.
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			edits = append(edits, edit{opInsert, b[y-1]})
+		} else {
+			edits = append(edits, edit{opDelete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// hunk is a range [start, end) of an edit script.
+type hunk struct {
+	start, end int
+}
+
+// hunks groups the changes in edits into hunks with diffContext lines of
+// context. Changes separated by fewer than 2*diffContext equal lines share a
+// hunk.
+func hunks(edits []edit) []hunk {
+	var result []hunk
+	for i := 0; i < len(edits); i++ {
+		if edits[i].op == opEqual {
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i + 1
+		for j := i + 1; j < len(edits); j++ {
+			if edits[j].op != opEqual {
+				end = j + 1
+				continue
+			}
+			if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(edits))
+		result = append(result, hunk{start, end})
+		i = end - 1
+	}
+	return result
+}
+
+// writeHunk writes a single hunk with its @@ header.
+func writeHunk(out *strings.Builder, edits []edit, h hunk) {
+	lineA, lineB := 1, 1
+	for _, e := range edits[:h.start] {
+		if e.op != opInsert {
+			lineA++
+		}
+		if e.op != opDelete {
+			lineB++
+		}
+	}
+	countA, countB := 0, 0
+	for _, e := range edits[h.start:h.end] {
+		if e.op != opInsert {
+			countA++
+		}
+		if e.op != opDelete {
+			countB++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(lineA, countA), hunkRange(lineB, countB))
+	for _, e := range edits[h.start:h.end] {
+		prefix := " "
+		switch e.op {
+		case opDelete:
+			prefix = "-"
+		case opInsert:
+			prefix = "+"
+		}
+		out.WriteString(prefix + e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the line range of one side of a hunk header.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{
+			name:     "Equal",
+			a:        "a\nb\n",
+			b:        "a\nb\n",
+			expected: "",
+		},
+		{
+			name:     "Single change",
+			a:        "a\nb\nc\n",
+			b:        "a\nB\nc\n",
+			expected: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:     "Insert into empty",
+			a:        "",
+			b:        "x\n",
+			expected: "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n",
+		},
+		{
+			name:     "Missing final newline",
+			a:        "a\nb",
+			b:        "a\nb\n",
+			expected: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "Separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+			expected: "--- a\n+++ b\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+y\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, unifiedDiff("a", "b", tt.a, tt.b))
+		})
+	}
+}
+
+func TestDiffLines_ShortestScript(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	edits := diffLines(a, b)
+
+	var gotA, gotB []string
+	changes := 0
+	for _, e := range edits {
+		if e.op != opInsert {
+			gotA = append(gotA, e.line)
+		}
+		if e.op != opDelete {
+			gotB = append(gotB, e.line)
+		}
+		if e.op != opEqual {
+			changes++
+		}
+	}
+	require.Equal(t, a, gotA)
+	require.Equal(t, b, gotB)
+	require.Equal(t, 5, changes, "the classic Myers example has an edit distance of 5")
+}
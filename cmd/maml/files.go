@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mamlerrors "github.com/KimNorgaard/go-maml/errors"
+)
+
+// stdinName is the name used for standard input in diagnostics.
+const stdinName = "<stdin>"
+
+// expandPaths replaces every directory in paths with the .maml files it
+// contains, recursively. Plain files are returned as given.
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, ".maml") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readInput reads the named file, or stdin if name is stdinName.
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == stdinName {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(name) //nolint:gosec // reading user-supplied paths is the purpose of the tool.
+}
+
+// reportError writes err to w. Parse errors are written one per line,
+// prefixed with name:line:column.
+func reportError(w io.Writer, name string, err error) {
+	var perrs mamlerrors.ParseErrors
+	if errors.As(err, &perrs) {
+		for _, e := range perrs {
+			fmt.Fprintf(w, "%s:%d:%d: %s\n", name, e.Line, e.Column, e.Message)
+		}
+		return
+	}
+	fmt.Fprintf(w, "%s: %v\n", name, err)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/KimNorgaard/go-maml"
+)
+
+// fmtFlags holds the flags of the fmt command.
+type fmtFlags struct {
+	write          bool
+	list           bool
+	diff           bool
+	indent         int
+	fieldCommas    bool
+	trailingCommas bool
+	inlineArrays   bool
+	width          int
+}
+
+// options returns the encoder options selected by the flags.
+func (f *fmtFlags) options() []maml.Option {
+	opts := []maml.Option{maml.Indent(f.indent)}
+	if f.fieldCommas {
+		opts = append(opts, maml.UseFieldCommas())
+	}
+	if f.trailingCommas {
+		opts = append(opts, maml.UseTrailingCommas())
+	}
+	if f.inlineArrays {
+		opts = append(opts, maml.InlineArrays())
+	}
+	if f.width > 0 {
+		opts = append(opts, maml.MaxLineWidth(f.width))
+	}
+	return opts
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var ff fmtFlags
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: maml fmt [flags] [path ...]")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&ff.write, "w", false, "write result to (source) file instead of stdout")
+	fs.BoolVar(&ff.list, "l", false, "list files whose formatting differs from maml fmt's")
+	fs.BoolVar(&ff.diff, "d", false, "display diffs instead of rewriting files")
+	fs.IntVar(&ff.indent, "indent", 2, "number of spaces per indentation level")
+	fs.BoolVar(&ff.fieldCommas, "commas", false, "separate pairs and elements with commas")
+	fs.BoolVar(&ff.trailingCommas, "trailing-commas", false, "add a comma after the last pair or element (requires -commas)")
+	fs.BoolVar(&ff.inlineArrays, "inline-arrays", false, "write arrays on a single line")
+	fs.IntVar(&ff.width, "width", 0, "keep arrays and objects on one line when they fit in this many columns (0 expands them)")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		if ff.write {
+			fmt.Fprintln(stderr, "maml fmt: cannot use -w with standard input")
+			return exitUsage
+		}
+		if err := formatFile(stdinName, stdin, stdout, &ff); err != nil {
+			reportError(stderr, stdinName, err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	files, err := expandPaths(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "maml fmt: %v\n", err)
+		return exitError
+	}
+	code := exitOK
+	for _, name := range files {
+		if err := formatFile(name, stdin, stdout, &ff); err != nil {
+			reportError(stderr, name, err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+// formatFile formats a single file and reports the result according to the
+// flags.
+func formatFile(name string, stdin io.Reader, stdout io.Writer, ff *fmtFlags) error {
+	src, err := readInput(name, stdin)
+	if err != nil {
+		return err
+	}
+	res, err := format(src, ff.options())
+	if err != nil {
+		return err
+	}
+
+	changed := !bytes.Equal(src, res)
+	if ff.list && changed {
+		fmt.Fprintln(stdout, name)
+	}
+	if ff.write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if ff.diff && changed {
+		fmt.Fprintf(stdout, "diff %s.orig %s\n", name, name)
+		if _, err := io.WriteString(stdout, unifiedDiff(name+".orig", name, string(src), string(res))); err != nil {
+			return err
+		}
+	}
+	if !ff.list && !ff.write && !ff.diff {
+		_, err := stdout.Write(res)
+		return err
+	}
+	return nil
+}
+
+// format returns the canonical formatting of src, preserving comments.
+// The result always ends with a newline.
+func format(src []byte, opts []maml.Option) ([]byte, error) {
+	doc, err := maml.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	out, err := maml.Marshal(doc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes used by the language server.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response. Requests
+// and responses carry an ID; notifications do not.
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcError is the error object of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// rpcConn reads and writes JSON-RPC messages framed with the Content-Length
+// headers of the Language Server Protocol base protocol.
+type rpcConn struct {
+	r  *bufio.Reader
+	mu sync.Mutex // serialises writes
+	w  io.Writer
+}
+
+func newRPCConn(r io.Reader, w io.Writer) *rpcConn {
+	return &rpcConn{r: bufio.NewReader(r), w: w}
+}
+
+// read returns the next message. It returns io.EOF when the stream ends
+// between messages.
+func (c *rpcConn) read() (*rpcMessage, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+// write sends msg, filling in the protocol version.
+func (c *rpcConn) write(msg *rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// reply sends the response to the request with the given ID. A nil result
+// is sent as JSON null.
+func (c *rpcConn) reply(id *json.RawMessage, result any, rerr *rpcError) error {
+	msg := &rpcMessage{ID: id, Error: rerr}
+	if rerr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = (*json.RawMessage)(&raw)
+	}
+	return c.write(msg)
+}
+
+// notify sends a notification.
+func (c *rpcConn) notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&rpcMessage{Method: method, Params: raw})
+}
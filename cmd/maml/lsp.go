@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
+	mamlerrors "github.com/KimNorgaard/go-maml/errors"
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	schemaFile := fs.String("schema", "", "validate documents against the schema in `file`")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: maml lsp [-schema file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	s := &lspServer{conn: newRPCConn(stdin, stdout), docs: make(map[string]*lspDocument)}
+	if *schemaFile != "" {
+		var err error
+		if s.schema, err = loadSchema(*schemaFile); err != nil {
+			fmt.Fprintf(stderr, "maml lsp: %v\n", err)
+			return exitError
+		}
+	}
+	if err := s.serve(); err != nil {
+		fmt.Fprintf(stderr, "maml lsp: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// lspServer is a Language Server Protocol server for MAML documents. It
+// speaks JSON-RPC over a single connection and handles one message at a time.
+type lspServer struct {
+	conn     *rpcConn
+	schema   *schema // nil if documents are not validated
+	docs     map[string]*lspDocument
+	shutdown bool // set by the shutdown request
+}
+
+// lspDocument is an open text document.
+type lspDocument struct {
+	text       []byte
+	lineStarts []int // byte offset of the start of each line
+	doc        *ast.Document
+	err        error // the syntax errors of doc
+}
+
+func newLSPDocument(text string) *lspDocument {
+	d := &lspDocument{text: []byte(text), lineStarts: []int{0}}
+	for i, b := range d.text {
+		if b == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	d.doc, d.err = maml.Parse(d.text)
+	return d
+}
+
+// errExitWithoutShutdown is returned by serve when the client sends exit
+// before shutdown, or closes the connection.
+var errExitWithoutShutdown = errors.New("connection closed before shutdown")
+
+// serve handles messages until the client sends the exit notification.
+func (s *lspServer) serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			var rerr *rpcError
+			if errors.As(err, &rerr) {
+				// The ID of an unreadable request is unknown, which
+				// JSON-RPC requires to be sent as null.
+				id := json.RawMessage("null")
+				if err := s.conn.reply(&id, nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return errExitWithoutShutdown
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a request or notification and sends the response.
+func (s *lspServer) handle(msg *rpcMessage) error {
+	result, rerr := s.dispatch(msg)
+	if msg.ID == nil {
+		return nil // notifications have no response
+	}
+	return s.conn.reply(msg.ID, result, rerr)
+}
+
+func (s *lspServer) dispatch(msg *rpcMessage) (any, *rpcError) { //nolint:gocognit
+	if s.shutdown && msg.ID != nil {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "server is shut down"}
+	}
+	switch msg.Method {
+	case "initialize":
+		return lspInitializeResult{
+			Capabilities: lspServerCapabilities{
+				TextDocumentSync:           lspTextDocumentSyncOptions{OpenClose: true, Change: syncFull},
+				DocumentFormattingProvider: true,
+				DocumentSymbolProvider:     true,
+				FoldingRangeProvider:       true,
+				HoverProvider:              true,
+			},
+			ServerInfo: lspServerInfo{Name: "maml"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := unmarshalParams(msg, &p); err != nil {
+			return nil, err
+		}
+		return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := unmarshalParams(msg, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// With full synchronisation the last change holds the whole text.
+		return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := unmarshalParams(msg, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.publish(p.TextDocument.URI, []lspDiagnostic{})
+	case "textDocument/formatting":
+		var p struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+			Options      struct {
+				TabSize int `json:"tabSize"`
+			} `json:"options"`
+		}
+		d, err := s.document(msg, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.formatting(p.Options.TabSize), nil
+	case "textDocument/documentSymbol":
+		var p struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		d, err := s.document(msg, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+	case "textDocument/foldingRange":
+		var p struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		d, err := s.document(msg, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		return d.foldingRanges(), nil
+	case "textDocument/hover":
+		var p struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+			Position     lspPosition               `json:"position"`
+		}
+		d, err := s.document(msg, &p, &p.TextDocument)
+		if err != nil {
+			return nil, err
+		}
+		if h := d.hover(p.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	default:
+		if msg.ID == nil {
+			return nil, nil // unknown notifications are ignored
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+}
+
+// unmarshalParams decodes the parameters of msg into v.
+func unmarshalParams(msg *rpcMessage, v any) *rpcError {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// document decodes the parameters of msg into params and returns the open
+// document identified by id.
+func (s *lspServer) document(msg *rpcMessage, params any, id *lspTextDocumentIdentifier) (*lspDocument, *rpcError) {
+	if err := unmarshalParams(msg, params); err != nil {
+		return nil, err
+	}
+	d, ok := s.docs[id.URI]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "document not open: " + id.URI}
+	}
+	return d, nil
+}
+
+// update replaces the text of the document and publishes its diagnostics.
+func (s *lspServer) update(uri, text string) *rpcError {
+	d := newLSPDocument(text)
+	s.docs[uri] = d
+	return s.publish(uri, d.diagnostics(s.schema))
+}
+
+func (s *lspServer) publish(uri string, diags []lspDiagnostic) *rpcError {
+	params := struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}{uri, diags}
+	if err := s.conn.notify("textDocument/publishDiagnostics", params); err != nil {
+		return &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+// diagnostics returns the syntax errors of the document followed by the
+// values that do not match sch.
+func (d *lspDocument) diagnostics(sch *schema) []lspDiagnostic {
+	diags := []lspDiagnostic{}
+	var perrs mamlerrors.ParseErrors
+	if errors.As(d.err, &perrs) {
+		for _, e := range perrs {
+			start, end := e.Span()
+			diags = append(diags, d.diagnostic(start, end, e.Message))
+		}
+	}
+	for _, e := range sch.validate(d.doc) {
+		diags = append(diags, d.diagnostic(e.Start, e.End, e.Message))
+	}
+	return diags
+}
+
+func (d *lspDocument) diagnostic(start, end token.Position, msg string) lspDiagnostic {
+	r := d.lspRange(start, end)
+	if r.End == r.Start {
+		r.End.Character++ // make empty spans visible
+	}
+	return lspDiagnostic{Range: r, Severity: severityError, Source: "maml", Message: msg}
+}
+
+// formatting returns the edits that format the document, or nil if it
+// cannot be parsed.
+func (d *lspDocument) formatting(tabSize int) []lspTextEdit {
+	if d.err != nil {
+		return nil
+	}
+	if tabSize <= 0 {
+		tabSize = 2
+	}
+	out, err := format(d.text, []maml.Option{maml.Indent(tabSize)})
+	if err != nil {
+		return nil
+	}
+	if bytes.Equal(out, d.text) {
+		return []lspTextEdit{}
+	}
+	return []lspTextEdit{{Range: lspRange{End: d.position(len(d.text))}, NewText: string(out)}}
+}
+
+// symbols returns the key tree of the document.
+func (d *lspDocument) symbols() []lspDocumentSymbol {
+	if d.doc == nil {
+		return []lspDocumentSymbol{}
+	}
+	return d.childSymbols(d.doc.Root())
+}
+
+// childSymbols returns the symbols for the pairs of an object, or for the
+// elements of an array that are objects or arrays themselves.
+func (d *lspDocument) childSymbols(n ast.Expression) []lspDocumentSymbol {
+	syms := []lspDocumentSymbol{}
+	switch n := n.(type) {
+	case *ast.ObjectLiteral:
+		for _, pair := range n.Pairs {
+			name := pair.KeyString()
+			if name == "" {
+				name = `""`
+			}
+			syms = append(syms, d.symbol(name, pair.Value, pair.Pos(), pair.End(), pair.Key))
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range n.Elements {
+			switch el.Value.(type) {
+			case *ast.ObjectLiteral, *ast.ArrayLiteral:
+				syms = append(syms, d.symbol(fmt.Sprintf("[%d]", i), el.Value, el.Pos(), el.End(), el.Value))
+			}
+		}
+	}
+	return syms
+}
+
+func (d *lspDocument) symbol(name string, value ast.Expression, start, end token.Position, sel ast.Node) lspDocumentSymbol {
+	sym := lspDocumentSymbol{
+		Name:           name,
+		Kind:           symbolKind(value),
+		Range:          d.lspRange(start, end),
+		SelectionRange: d.lspRange(sel.Pos(), sel.End()),
+	}
+	switch value.(type) {
+	case *ast.ObjectLiteral, *ast.ArrayLiteral:
+		sym.Children = d.childSymbols(value)
+	case *ast.BadExpression:
+		// Syntax errors have no meaningful detail.
+	default:
+		sym.Detail = value.String()
+	}
+	return sym
+}
+
+// symbolKind returns the LSP symbol kind for a value.
+func symbolKind(n ast.Expression) int {
+	switch n.(type) {
+	case *ast.ObjectLiteral:
+		return symbolObject
+	case *ast.ArrayLiteral:
+		return symbolArray
+	case *ast.StringLiteral:
+		return symbolString
+	case *ast.IntegerLiteral, *ast.FloatLiteral:
+		return symbolNumber
+	case *ast.BooleanLiteral:
+		return symbolBoolean
+	case *ast.NullLiteral:
+		return symbolNull
+	default:
+		return symbolKey
+	}
+}
+
+// foldingRanges returns a range for every object, array and string that
+// spans multiple lines, ordered by start line.
+func (d *lspDocument) foldingRanges() []lspFoldingRange {
+	ranges := []lspFoldingRange{}
+	if d.doc == nil {
+		return ranges
+	}
+	var walk func(n ast.Expression)
+	walk = func(n ast.Expression) {
+		start, end := n.Pos(), n.End()
+		if !start.IsValid() || !end.IsValid() {
+			return
+		}
+		switch n := n.(type) {
+		case *ast.ObjectLiteral:
+			ranges = d.appendFold(ranges, start, n.EndToken.Pos())
+			for _, pair := range n.Pairs {
+				walk(pair.Value)
+			}
+		case *ast.ArrayLiteral:
+			ranges = d.appendFold(ranges, start, n.EndToken.Pos())
+			for _, el := range n.Elements {
+				walk(el.Value)
+			}
+		case *ast.StringLiteral:
+			if end.Line > start.Line {
+				ranges = append(ranges, lspFoldingRange{StartLine: start.Line - 1, EndLine: end.Line - 1})
+			}
+		}
+	}
+	if root := d.doc.Root(); root != nil {
+		walk(root)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].StartLine < ranges[j].StartLine })
+	return ranges
+}
+
+// appendFold appends the folding range of a bracketed value that starts at
+// start and is closed at closer. A closing bracket on a line of its own is
+// left visible when folded.
+func (d *lspDocument) appendFold(ranges []lspFoldingRange, start, closer token.Position) []lspFoldingRange {
+	if !closer.IsValid() {
+		return ranges
+	}
+	endLine := closer.Line - 1
+	if line := closer.Line - 1; line < len(d.lineStarts) &&
+		len(bytes.TrimSpace(d.text[d.lineStarts[line]:closer.Offset])) == 0 {
+		endLine--
+	}
+	if endLine <= start.Line-1 {
+		return ranges
+	}
+	return append(ranges, lspFoldingRange{StartLine: start.Line - 1, EndLine: endLine})
+}
+
+// hover describes the innermost key or value at pos by its path.
+func (d *lspDocument) hover(pos lspPosition) *lspHover {
+	if d.doc == nil || d.doc.Root() == nil {
+		return nil
+	}
+	offset := d.offset(pos)
+	path, n := lookupOffset(d.doc.Root(), offset, ast.Path{})
+	if n == nil {
+		return nil
+	}
+	label := path.String()
+	if label == "" {
+		label = "(root)"
+	}
+	r := d.lspRange(n.Pos(), n.End())
+	return &lspHover{
+		Contents: lspMarkupContent{Kind: "markdown", Value: fmt.Sprintf("`%s` (%s)", label, valueKind(n))},
+		Range:    &r,
+	}
+}
+
+// lookupOffset returns the innermost value containing the byte offset,
+// along with its path. A key resolves to the value of its pair.
+func lookupOffset(n ast.Expression, offset int, path ast.Path) (ast.Path, ast.Expression) {
+	if !contains(n, offset) {
+		return nil, nil
+	}
+	switch n := n.(type) {
+	case *ast.ObjectLiteral:
+		for _, pair := range n.Pairs {
+			keyPath := append(path[:len(path):len(path)], ast.PathSegment{Key: pair.KeyString()})
+			if contains(pair.Key, offset) {
+				return keyPath, pair.Value
+			}
+			if p, v := lookupOffset(pair.Value, offset, keyPath); v != nil {
+				return p, v
+			}
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range n.Elements {
+			elPath := append(path[:len(path):len(path)], ast.PathSegment{Index: i, IsIndex: true})
+			if p, v := lookupOffset(el.Value, offset, elPath); v != nil {
+				return p, v
+			}
+		}
+	}
+	return path, n
+}
+
+// contains reports whether the byte offset lies within n.
+func contains(n ast.Node, offset int) bool {
+	if n == nil || !n.Pos().IsValid() || !n.End().IsValid() {
+		return false
+	}
+	return n.Pos().Offset <= offset && offset < n.End().Offset
+}
+
+// lspRange converts a source span to an LSP range.
+func (d *lspDocument) lspRange(start, end token.Position) lspRange {
+	r := lspRange{Start: d.position(start.Offset), End: d.position(end.Offset)}
+	if !end.IsValid() || end.Offset < start.Offset {
+		r.End = r.Start
+	}
+	return r
+}
+
+// position converts a byte offset to an LSP position, whose character is
+// counted in UTF-16 code units.
+func (d *lspDocument) position(offset int) lspPosition {
+	offset = min(max(offset, 0), len(d.text))
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	return lspPosition{Line: line, Character: utf16Len(d.text[d.lineStarts[line]:offset])}
+}
+
+// offset converts an LSP position to a byte offset, clamping it to the line.
+func (d *lspDocument) offset(pos lspPosition) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+	i := d.lineStarts[pos.Line]
+	for units := 0; i < len(d.text) && d.text[i] != '\n' && units < pos.Character; {
+		r, size := utf8.DecodeRune(d.text[i:])
+		units += utf16.RuneLen(r)
+		i += size
+	}
+	return i
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode b.
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError {
+			n++
+		} else {
+			n += utf16.RuneLen(r)
+		}
+		b = b[size:]
+	}
+	return n
+}
+
+// Protocol constants.
+const (
+	syncFull      = 1 // TextDocumentSyncKind.Full
+	severityError = 1 // DiagnosticSeverity.Error
+
+	symbolString  = 15
+	symbolNumber  = 16
+	symbolBoolean = 17
+	symbolArray   = 18
+	symbolObject  = 19
+	symbolKey     = 20
+	symbolNull    = 21
+)
+
+// Protocol types, restricted to the fields used by the server.
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+type lspFoldingRange struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+type lspMarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type lspHover struct {
+	Contents lspMarkupContent `json:"contents"`
+	Range    *lspRange        `json:"range,omitempty"`
+}
+
+type lspTextDocumentSyncOptions struct {
+	OpenClose bool `json:"openClose"`
+	Change    int  `json:"change"`
+}
+
+type lspServerCapabilities struct {
+	TextDocumentSync           lspTextDocumentSyncOptions `json:"textDocumentSync"`
+	DocumentFormattingProvider bool                       `json:"documentFormattingProvider"`
+	DocumentSymbolProvider     bool                       `json:"documentSymbolProvider"`
+	FoldingRangeProvider       bool                       `json:"foldingRangeProvider"`
+	HoverProvider              bool                       `json:"hoverProvider"`
+}
+
+type lspServerInfo struct {
+	Name string `json:"name"`
+}
+
+type lspInitializeResult struct {
+	Capabilities lspServerCapabilities `json:"capabilities"`
+	ServerInfo   lspServerInfo         `json:"serverInfo"`
+}
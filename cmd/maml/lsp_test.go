@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// lspClient drives an in-process language server over a pair of pipes.
+type lspClient struct {
+	t             *testing.T
+	conn          *rpcConn
+	msgs          chan *rpcMessage // messages received from the server
+	nextID        int
+	notifications []*rpcMessage
+	done          chan int // receives the exit code of the server
+}
+
+// startLSP starts a server with the given arguments and returns a client
+// connected to it.
+func startLSP(t *testing.T, args ...string) *lspClient {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &lspClient{
+		t:    t,
+		conn: newRPCConn(clientIn, clientOut),
+		msgs: make(chan *rpcMessage, 64),
+		done: make(chan int, 1),
+	}
+	go func() {
+		code := runLSP(args, serverIn, serverOut, io.Discard)
+		_ = serverOut.Close()
+		c.done <- code
+	}()
+	// Read continuously, so that the server never blocks on a write while
+	// the client is writing a request.
+	go func() {
+		defer close(c.msgs)
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+		_ = clientIn.Close()
+	})
+	return c
+}
+
+// newLSPClient starts a server and initializes it.
+func newLSPClient(t *testing.T, args ...string) *lspClient {
+	t.Helper()
+	c := startLSP(t, args...)
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, nil)
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+// call sends a request and decodes its result into result. Notifications
+// received in the meantime are recorded.
+func (c *lspClient) call(method string, params, result any) *rpcError {
+	c.t.Helper()
+	c.nextID++
+	raw, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	require.NoError(c.t, c.conn.write(&rpcMessage{ID: &id, Method: method, Params: raw}))
+	for {
+		var msg *rpcMessage
+		select {
+		case msg = <-c.msgs:
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("no response to %s", method)
+		}
+		require.NotNil(c.t, msg, "connection closed")
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		require.Equal(c.t, string(id), string(*msg.ID))
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			raw := json.RawMessage("null") // a null result decodes as a nil Result
+			if msg.Result != nil {
+				raw = *msg.Result
+			}
+			require.NoError(c.t, json.Unmarshal(raw, result))
+		}
+		return nil
+	}
+}
+
+func (c *lspClient) notify(method string, params any) {
+	c.t.Helper()
+	raw, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&rpcMessage{Method: method, Params: raw}))
+}
+
+// open opens a document and returns the diagnostics published for it.
+func (c *lspClient) open(uri, text string) []lspDiagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "maml", "version": 1, "text": text},
+	})
+	return c.diagnostics(uri)
+}
+
+// diagnostics waits for the next diagnostics published for uri. A round
+// trip through a request guarantees that preceding notifications arrived.
+func (c *lspClient) diagnostics(uri string) []lspDiagnostic {
+	c.t.Helper()
+	c.call("textDocument/documentSymbol", docParams(uri), nil)
+	for i, msg := range c.notifications {
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p struct {
+			URI         string          `json:"uri"`
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		require.NoError(c.t, json.Unmarshal(msg.Params, &p))
+		if p.URI == uri {
+			c.notifications = append(c.notifications[:i], c.notifications[i+1:]...)
+			require.NotNil(c.t, p.Diagnostics)
+			return p.Diagnostics
+		}
+	}
+	c.t.Fatalf("no diagnostics published for %s", uri)
+	return nil
+}
+
+func docParams(uri string) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}}
+}
+
+func rng(startLine, startChar, endLine, endChar int) lspRange {
+	return lspRange{Start: lspPosition{startLine, startChar}, End: lspPosition{endLine, endChar}}
+}
+
+const lspDoc = `{
+  name: "demo"
+  server: {
+    host: "localhost"
+    ports: [80, 443]
+  }
+  tags: [{ id: 1 }, "x"]
+  motd: """
+Welcome
+"""
+}`
+
+func TestLSP_Lifecycle(t *testing.T) {
+	c := newLSPClient(t)
+
+	rerr := c.call("workspace/symbol", map[string]any{"query": ""}, nil)
+	require.NotNil(t, rerr)
+	require.Equal(t, codeMethodNotFound, rerr.Code)
+
+	require.Nil(t, c.call("shutdown", nil, nil))
+	c.notify("exit", nil)
+	require.Equal(t, exitOK, <-c.done)
+}
+
+func TestLSP_ExitWithoutShutdown(t *testing.T) {
+	c := newLSPClient(t)
+	c.notify("exit", nil)
+	require.Equal(t, exitError, <-c.done)
+}
+
+func TestLSP_Initialize(t *testing.T) {
+	c := startLSP(t)
+	var res lspInitializeResult
+	require.Nil(t, c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &res))
+	require.Equal(t, "maml", res.ServerInfo.Name)
+	require.Equal(t, lspServerCapabilities{
+		TextDocumentSync:           lspTextDocumentSyncOptions{OpenClose: true, Change: syncFull},
+		DocumentFormattingProvider: true,
+		DocumentSymbolProvider:     true,
+		FoldingRangeProvider:       true,
+		HoverProvider:              true,
+	}, res.Capabilities)
+}
+
+func TestLSP_Diagnostics(t *testing.T) {
+	c := newLSPClient(t)
+
+	t.Run("Syntax errors", func(t *testing.T) {
+		diags := c.open("file:///a.maml", "{\n  \"ü\": 1x\n  b: [1, 2\n}")
+		require.Equal(t, []lspDiagnostic{
+			{Range: rng(1, 7, 1, 9), Severity: severityError, Source: "maml", Message: "invalid number format: 1x"},
+			{Range: rng(3, 0, 3, 1), Severity: severityError, Source: "maml", Message: "unterminated array literal, expected ']' got }"},
+		}, diags)
+	})
+
+	t.Run("Cleared after a fix", func(t *testing.T) {
+		c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": "file:///a.maml", "version": 2},
+			"contentChanges": []map[string]any{{"text": "{ a: 1 }"}},
+		})
+		require.Empty(t, c.diagnostics("file:///a.maml"))
+	})
+
+	t.Run("Cleared on close", func(t *testing.T) {
+		require.NotEmpty(t, c.open("file:///b.maml", "{"))
+		c.notify("textDocument/didClose", docParams("file:///b.maml"))
+		require.Nil(t, c.call("shutdown", nil, nil)) // flushes the notification
+		require.Len(t, c.notifications, 1)
+	})
+}
+
+func TestLSP_SchemaDiagnostics(t *testing.T) {
+	schemaFile := writeFile(t, t.TempDir(), "schema.maml", `{
+  name: "string"
+  replicas: "int"
+  ratio: "float?"
+  tags: ["string"]
+  spec: { image: "string", env: "object" }
+  mode: "int"
+  label: "string"
+  extra: "any"
+}`)
+	c := newLSPClient(t, "-schema", schemaFile)
+
+	diags := c.open("file:///app.maml", `{
+  name: "app"
+  replicas: "three"
+  ratio: 1
+  tags: ["a", 2]
+  spec: { image: "nginx", env: [], debug: true }
+  mode: fast
+  label: fast
+  extra: null
+}`)
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.Message)
+	}
+	require.Equal(t, []string{
+		`replicas: expected int, got string`,
+		`tags[1]: expected string, got int`,
+		`spec.env: expected object, got array`,
+		`unknown key "spec.debug"`,
+		`mode: expected int, got identifier`,
+	}, got)
+	require.Equal(t, rng(2, 12, 2, 19), diags[0].Range)
+	require.Equal(t, rng(5, 35, 5, 40), diags[3].Range)
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		bad := writeFile(t, t.TempDir(), "bad.maml", `{ a: "integer" }`)
+		code, _, stderr := runCmd(t, "", "lsp", "-schema", bad)
+		require.Equal(t, exitError, code)
+		require.Contains(t, stderr, `1:6 (a): unknown type "integer"`)
+	})
+}
+
+func TestLSP_Formatting(t *testing.T) {
+	c := newLSPClient(t)
+	c.open("file:///a.maml", "{a:1,\n# about b\nb:[1,2]}")
+
+	var edits []lspTextEdit
+	params := docParams("file:///a.maml")
+	params["options"] = map[string]any{"tabSize": 4, "insertSpaces": true}
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	require.Equal(t, []lspTextEdit{{
+		Range:   rng(0, 0, 2, 8),
+		NewText: "{\n    a: 1\n    # about b\n    b: [\n        1\n        2\n    ]\n}\n",
+	}}, edits)
+
+	c.open("file:///bad.maml", "{a:")
+	edits = []lspTextEdit{{}}
+	require.Nil(t, c.call("textDocument/formatting", docParams("file:///bad.maml"), &edits))
+	require.Nil(t, edits)
+
+	rerr := c.call("textDocument/formatting", docParams("file:///missing.maml"), nil)
+	require.NotNil(t, rerr)
+	require.Equal(t, codeInvalidParams, rerr.Code)
+}
+
+func TestLSP_DocumentSymbols(t *testing.T) {
+	c := newLSPClient(t)
+	c.open("file:///a.maml", lspDoc)
+
+	var syms []lspDocumentSymbol
+	require.Nil(t, c.call("textDocument/documentSymbol", docParams("file:///a.maml"), &syms))
+	require.Equal(t, []lspDocumentSymbol{
+		{Name: "name", Detail: `"demo"`, Kind: symbolString, Range: rng(1, 2, 1, 14), SelectionRange: rng(1, 2, 1, 6)},
+		{Name: "server", Kind: symbolObject, Range: rng(2, 2, 5, 3), SelectionRange: rng(2, 2, 2, 8), Children: []lspDocumentSymbol{
+			{Name: "host", Detail: `"localhost"`, Kind: symbolString, Range: rng(3, 4, 3, 21), SelectionRange: rng(3, 4, 3, 8)},
+			{Name: "ports", Kind: symbolArray, Range: rng(4, 4, 4, 20), SelectionRange: rng(4, 4, 4, 9)},
+		}},
+		{Name: "tags", Kind: symbolArray, Range: rng(6, 2, 6, 24), SelectionRange: rng(6, 2, 6, 6), Children: []lspDocumentSymbol{
+			{Name: "[0]", Kind: symbolObject, Range: rng(6, 9, 6, 18), SelectionRange: rng(6, 9, 6, 18), Children: []lspDocumentSymbol{
+				{Name: "id", Detail: "1", Kind: symbolNumber, Range: rng(6, 11, 6, 16), SelectionRange: rng(6, 11, 6, 13)},
+			}},
+		}},
+		{Name: "motd", Detail: `"Welcome\n"`, Kind: symbolString, Range: rng(7, 2, 9, 3), SelectionRange: rng(7, 2, 7, 6)},
+	}, syms)
+}
+
+func TestLSP_FoldingRanges(t *testing.T) {
+	c := newLSPClient(t)
+	c.open("file:///a.maml", lspDoc+"\n\n[\n  1,\n  2]")
+
+	var ranges []lspFoldingRange
+	require.Nil(t, c.call("textDocument/foldingRange", docParams("file:///a.maml"), &ranges))
+	require.Equal(t, []lspFoldingRange{
+		{StartLine: 0, EndLine: 9},
+		{StartLine: 2, EndLine: 4},
+		{StartLine: 7, EndLine: 9},
+	}, ranges)
+
+	c.open("file:///b.maml", "[\n  1,\n  2]")
+	require.Nil(t, c.call("textDocument/foldingRange", docParams("file:///b.maml"), &ranges))
+	require.Equal(t, []lspFoldingRange{{StartLine: 0, EndLine: 2}}, ranges)
+}
+
+func TestLSP_Hover(t *testing.T) {
+	c := newLSPClient(t)
+	c.open("file:///a.maml", lspDoc)
+
+	hover := func(line, char int) *lspHover {
+		t.Helper()
+		params := docParams("file:///a.maml")
+		params["position"] = lspPosition{line, char}
+		var h *lspHover
+		require.Nil(t, c.call("textDocument/hover", params, &h))
+		return h
+	}
+
+	h := hover(4, 17) // the 443 in ports
+	require.NotNil(t, h)
+	require.Equal(t, "`server.ports[1]` (int)", h.Contents.Value)
+	require.Equal(t, "markdown", h.Contents.Kind)
+	require.Equal(t, rng(4, 16, 4, 19), *h.Range)
+
+	require.Equal(t, "`server` (object)", hover(2, 3).Contents.Value)
+	require.Equal(t, "`tags[0].id` (int)", hover(6, 15).Contents.Value)
+	require.Equal(t, "`(root)` (object)", hover(1, 0).Contents.Value)
+	require.Nil(t, hover(20, 0))
+}
+
+func TestLSP_Positions(t *testing.T) {
+	d := newLSPDocument("{\n  \"😀é\": 1\n}")
+	// The emoji takes two UTF-16 code units and four bytes, é one unit and
+	// two bytes.
+	require.Equal(t, lspPosition{1, 7}, d.position(12))
+	require.Equal(t, 12, d.offset(lspPosition{1, 7}))
+	require.Equal(t, len(d.text)-2, d.offset(lspPosition{1, 100}))
+	require.Equal(t, len(d.text), d.offset(lspPosition{5, 0}))
+}
+
+func TestRPCConn_InvalidMessage(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go func() {
+		runLSP(nil, serverIn, serverOut, io.Discard)
+		_ = serverOut.Close()
+	}()
+	t.Cleanup(func() {
+		_ = clientOut.Close()
+		_ = clientIn.Close()
+	})
+	_, err := io.WriteString(clientOut, "Content-Length: 5\r\n\r\n{oops")
+	require.NoError(t, err)
+
+	// Read the raw response, as decoding it would not tell a null ID from
+	// a missing one.
+	r := bufio.NewReader(clientIn)
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	require.NoError(t, err)
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	require.NoError(t, err)
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	require.NoError(t, err)
+	var msg map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(body, &msg))
+	require.Contains(t, msg, "id")
+	require.Equal(t, "null", string(msg["id"]))
+	var rerr rpcError
+	require.NoError(t, json.Unmarshal(msg["error"], &rerr))
+	require.Equal(t, codeParseError, rerr.Code)
+}
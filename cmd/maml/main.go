@@ -0,0 +1,68 @@
+// Command maml formats, validates and converts MAML documents.
+//
+// Usage:
+//
+//	maml <command> [flags] [path ...]
+//
+// The commands are:
+//
+//	fmt      reformat MAML files
+//	check    report syntax errors in MAML files
+//	convert  translate between MAML and JSON
+//	lsp      run a language server over standard input and output
+//
+// Run "maml <command> -h" for the flags of a command.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: maml <command> [flags] [path ...]
+
+Commands:
+  fmt      reformat MAML files
+  check    report syntax errors in MAML files
+  convert  translate between MAML and JSON
+  lsp      run a language server over standard input and output
+
+Run "maml <command> -h" for the flags of a command.
+`
+
+// Exit codes shared by all commands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run dispatches args to the requested command and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	cmd, rest := args[0], args[1:]
+	switch cmd {
+	case "fmt":
+		return runFmt(rest, stdin, stdout, stderr)
+	case "check":
+		return runCheck(rest, stdin, stdout, stderr)
+	case "convert":
+		return runConvert(rest, stdin, stdout, stderr)
+	case "lsp":
+		return runLSP(rest, stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "maml: unknown command %q\n\n%s", cmd, usage)
+		return exitUsage
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformatted = "{a:1,b:[1,2]\n# about c\n  c: \"x\"}"
+	formatted   = "{\n  a: 1\n  b: [\n    1\n    2\n  ]\n  # about c\n  c: \"x\"\n}\n"
+)
+
+// runCmd runs the command line with the given stdin and returns the exit
+// code, stdout and stderr.
+func runCmd(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile writes content to name inside dir and returns its path.
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRun_Usage(t *testing.T) {
+	code, _, stderr := runCmd(t, "")
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr, "usage: maml <command>")
+
+	code, _, stderr = runCmd(t, "", "bogus")
+	require.Equal(t, exitUsage, code)
+	require.Contains(t, stderr, `unknown command "bogus"`)
+
+	code, stdout, _ := runCmd(t, "", "help")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, stdout, "Commands:")
+}
+
+func TestFmt(t *testing.T) {
+	t.Run("Stdin to stdout", func(t *testing.T) {
+		code, stdout, stderr := runCmd(t, unformatted, "fmt")
+		require.Equal(t, exitOK, code, stderr)
+		require.Equal(t, formatted, stdout)
+	})
+
+	t.Run("Encoder options", func(t *testing.T) {
+		code, stdout, _ := runCmd(t, unformatted, "fmt", "-indent", "4", "-commas", "-inline-arrays")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "{\n    a: 1,\n    b: [1,2],\n    # about c\n    c: \"x\"\n}\n", stdout)
+	})
+
+	t.Run("Line width", func(t *testing.T) {
+		code, stdout, _ := runCmd(t, unformatted, "fmt", "-width", "80")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "{\n  a: 1\n  b: [1, 2]\n  # about c\n  c: \"x\"\n}\n", stdout)
+	})
+
+	t.Run("List and write", func(t *testing.T) {
+		dir := t.TempDir()
+		bad := writeFile(t, dir, "bad.maml", unformatted)
+		good := writeFile(t, dir, "good.maml", formatted)
+		writeFile(t, dir, "ignored.txt", unformatted)
+
+		code, stdout, _ := runCmd(t, "", "fmt", "-l", dir)
+		require.Equal(t, exitOK, code)
+		require.Equal(t, bad+"\n", stdout)
+
+		code, stdout, _ = runCmd(t, "", "fmt", "-w", bad, good)
+		require.Equal(t, exitOK, code)
+		require.Empty(t, stdout)
+		content, err := os.ReadFile(bad)
+		require.NoError(t, err)
+		require.Equal(t, formatted, string(content))
+
+		code, stdout, _ = runCmd(t, "", "fmt", "-l", dir)
+		require.Equal(t, exitOK, code)
+		require.Empty(t, stdout)
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		path := writeFile(t, t.TempDir(), "x.maml", "{\n  a: 1\n  b:   2\n}\n")
+		code, stdout, _ := runCmd(t, "", "fmt", "-d", path)
+		require.Equal(t, exitOK, code)
+		require.Contains(t, stdout, "--- "+path+".orig\n+++ "+path+"\n")
+		require.Contains(t, stdout, "@@ -1,4 +1,4 @@\n {\n   a: 1\n-  b:   2\n+  b: 2\n }\n")
+	})
+
+	t.Run("Syntax errors", func(t *testing.T) {
+		code, _, stderr := runCmd(t, "{ a: }", "fmt")
+		require.Equal(t, exitError, code)
+		require.Contains(t, stderr, "<stdin>:1:6: ")
+	})
+
+	t.Run("Write requires files", func(t *testing.T) {
+		code, _, stderr := runCmd(t, unformatted, "fmt", "-w")
+		require.Equal(t, exitUsage, code)
+		require.Contains(t, stderr, "cannot use -w with standard input")
+	})
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "good.maml", formatted)
+	bad := writeFile(t, dir, "bad.maml", "{\n  a: \"x\n  b: 1\n}")
+
+	code, stdout, _ := runCmd(t, "", "check", dir)
+	require.Equal(t, exitError, code)
+	require.True(t, strings.HasPrefix(stdout, bad+":2:6: illegal token encountered: unterminated string\n"), stdout)
+	require.NotContains(t, stdout, "good.maml")
+
+	code, stdout, _ = runCmd(t, formatted, "check")
+	require.Equal(t, exitOK, code)
+	require.Empty(t, stdout)
+
+	code, stdout, _ = runCmd(t, "", "check", "-v", bad)
+	require.Equal(t, exitError, code)
+	require.True(t, strings.HasPrefix(stdout, bad+":2:6: error: illegal token encountered: unterminated string\n 2 |   a: \"x\n   |      ^~\n"), stdout)
+
+	code, _, stderr := runCmd(t, "", "check", filepath.Join(dir, "missing.maml"))
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "missing.maml")
+}
+
+func TestConvert(t *testing.T) {
+	t.Run("MAML to JSON", func(t *testing.T) {
+		path := writeFile(t, t.TempDir(), "in.maml", "{\n  name: \"a<b\" # comment\n  ports: [80, 443]\n}")
+		code, stdout, stderr := runCmd(t, "", "convert", path)
+		require.Equal(t, exitOK, code, stderr)
+		require.Equal(t, "{\n  \"name\": \"a<b\",\n  \"ports\": [\n    80,\n    443\n  ]\n}\n", stdout)
+	})
+
+	t.Run("JSON to MAML", func(t *testing.T) {
+		path := writeFile(t, t.TempDir(), "in.json", `{"big": 9007199254740993, "huge": 123456789012345678901234567890, "ratio": 0.5, "tags": ["x"], "none": null}`)
+		code, stdout, stderr := runCmd(t, "", "convert", path)
+		require.Equal(t, exitOK, code, stderr)
+		require.Equal(t, "{\n  big: 9007199254740993\n  huge: 123456789012345678901234567890\n  none: null\n  ratio: 0.5\n  tags: [\n    \"x\"\n  ]\n}\n", stdout)
+	})
+
+	t.Run("Explicit format from stdin", func(t *testing.T) {
+		code, stdout, _ := runCmd(t, "{a: 1}", "convert", "-to", "json", "-indent", "0")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "{\"a\":1}\n", stdout)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		code, _, stderr := runCmd(t, "{}", "convert")
+		require.Equal(t, exitUsage, code)
+		require.Contains(t, stderr, "cannot infer output format")
+
+		code, _, stderr = runCmd(t, "{}", "convert", "-to", "yaml")
+		require.Equal(t, exitUsage, code)
+		require.Contains(t, stderr, `unknown format "yaml"`)
+
+		code, _, stderr = runCmd(t, "{a: }", "convert", "-to", "json")
+		require.Equal(t, exitError, code)
+		require.Contains(t, stderr, "<stdin>:1:5: ")
+
+		code, _, stderr = runCmd(t, `{"a": 1} {}`, "convert", "-to", "maml")
+		require.Equal(t, exitError, code)
+		require.Contains(t, stderr, "unexpected data after top-level JSON value")
+
+		code, _, stderr = runCmd(t, `{"a": [1e400]}`, "convert", "-to", "maml")
+		require.Equal(t, exitError, code)
+		require.Contains(t, stderr, "value out of range")
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+// A schema describes the expected shape of a document by example. It is a
+// MAML document with the same structure as the documents it validates,
+// where every scalar is a string naming the expected type:
+//
+//	{
+//	  name: "string"
+//	  port: "int"
+//	  ratio: "float?"
+//	  tags: ["string"]
+//	  server: { host: "string", tls: "bool" }
+//	}
+//
+// The types are string, int, float (which also accepts integers), bool,
+// null, object, array and any. A trailing "?" also allows null. An object
+// in the schema allows only the keys it lists, all of which are optional.
+// An array with one element requires every element to match it; an empty
+// array allows any elements.
+type schema struct {
+	root ast.Expression
+}
+
+// schemaTypes lists the type names that may be used in a schema.
+var schemaTypes = map[string]bool{
+	"string": true, "int": true, "float": true, "bool": true,
+	"null": true, "object": true, "array": true, "any": true,
+}
+
+// loadSchema reads and checks the schema in the named file.
+func loadSchema(name string) (*schema, error) {
+	src, err := os.ReadFile(name) //nolint:gosec // the schema path is supplied by the user.
+	if err != nil {
+		return nil, err
+	}
+	doc, err := maml.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", name, err)
+	}
+	if err := checkSchema(doc.Root(), nil); err != nil {
+		return nil, fmt.Errorf("schema %s: %w", name, err)
+	}
+	return &schema{root: doc.Root()}, nil
+}
+
+// checkSchema reports the first invalid type in the schema node n.
+func checkSchema(n ast.Expression, path ast.Path) error {
+	switch n := n.(type) {
+	case *ast.ObjectLiteral:
+		for _, pair := range n.Pairs {
+			if err := checkSchema(pair.Value, append(path, ast.PathSegment{Key: pair.KeyString()})); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.ArrayLiteral:
+		if len(n.Elements) > 1 {
+			return fmt.Errorf("%s: array must have at most one element", schemaLocation(path, n.Pos()))
+		}
+		for _, el := range n.Elements {
+			if err := checkSchema(el.Value, append(path, ast.PathSegment{IsIndex: true})); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *ast.StringLiteral:
+		if !schemaTypes[strings.TrimSuffix(n.Value, "?")] {
+			return fmt.Errorf("%s: unknown type %q", schemaLocation(path, n.Pos()), n.Value)
+		}
+		return nil
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("%s: expected a type name, object or array", schemaLocation(path, n.Pos()))
+	}
+}
+
+// schemaLocation describes a location in a schema for error messages.
+func schemaLocation(path ast.Path, pos token.Position) string {
+	if len(path) == 0 {
+		return pos.String()
+	}
+	return pos.String() + " (" + path.String() + ")"
+}
+
+// schemaError is a value that does not match the schema.
+type schemaError struct {
+	Message    string
+	Start, End token.Position
+}
+
+// validate returns the values of doc that do not match the schema.
+func (s *schema) validate(doc *ast.Document) []schemaError {
+	if s == nil || doc == nil || doc.Root() == nil {
+		return nil
+	}
+	var errs []schemaError
+	s.validateNode(doc.Root(), s.root, nil, &errs)
+	return errs
+}
+
+func (s *schema) validateNode(n, want ast.Expression, path ast.Path, errs *[]schemaError) {
+	if _, ok := n.(*ast.BadExpression); ok {
+		return // already reported as a syntax error
+	}
+	mismatch := func(expected string) {
+		msg := fmt.Sprintf("expected %s, got %s", expected, valueKind(n))
+		if len(path) > 0 {
+			msg = path.String() + ": " + msg
+		}
+		*errs = append(*errs, schemaError{Message: msg, Start: n.Pos(), End: n.End()})
+	}
+
+	switch want := want.(type) {
+	case *ast.StringLiteral:
+		typ, nullable := strings.CutSuffix(want.Value, "?")
+		kind := valueKind(n)
+		if !(typ == "any" || typ == kind || typ == "float" && kind == "int" || typ == "string" && kind == "identifier" || nullable && kind == "null") {
+			mismatch(want.Value)
+		}
+	case *ast.ObjectLiteral:
+		obj, ok := n.(*ast.ObjectLiteral)
+		if !ok {
+			mismatch("object")
+			return
+		}
+		fields := make(map[string]ast.Expression, len(want.Pairs))
+		for _, pair := range want.Pairs {
+			fields[pair.KeyString()] = pair.Value
+		}
+		for _, pair := range obj.Pairs {
+			key := pair.KeyString()
+			keyPath := append(path[:len(path):len(path)], ast.PathSegment{Key: key})
+			field, ok := fields[key]
+			if !ok {
+				*errs = append(*errs, schemaError{
+					Message: fmt.Sprintf("unknown key %q", keyPath.String()),
+					Start:   pair.Key.Pos(),
+					End:     pair.Key.End(),
+				})
+				continue
+			}
+			s.validateNode(pair.Value, field, keyPath, errs)
+		}
+	case *ast.ArrayLiteral:
+		arr, ok := n.(*ast.ArrayLiteral)
+		if !ok {
+			mismatch("array")
+			return
+		}
+		if len(want.Elements) == 0 {
+			return
+		}
+		for i, el := range arr.Elements {
+			s.validateNode(el.Value, want.Elements[0].Value, append(path[:len(path):len(path)], ast.PathSegment{Index: i, IsIndex: true}), errs)
+		}
+	}
+}
+
+// valueKind returns the schema type name of the value n.
+func valueKind(n ast.Expression) string {
+	switch n.(type) {
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.Identifier:
+		return "identifier"
+	case *ast.IntegerLiteral:
+		return "int"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.BooleanLiteral:
+		return "bool"
+	case *ast.NullLiteral:
+		return "null"
+	case *ast.ObjectLiteral:
+		return "object"
+	case *ast.ArrayLiteral:
+		return "array"
+	default:
+		return "invalid value"
+	}
+}
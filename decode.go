@@ -5,13 +5,17 @@ import (
 	"encoding"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/KimNorgaard/go-maml/internal/lexer"
 	"github.com/KimNorgaard/go-maml/internal/parser"
+	"github.com/KimNorgaard/go-maml/token"
 )
 
 // Decoder reads and decodes MAML values from an input stream.
@@ -53,7 +57,14 @@ func (d *Decoder) Decode(out any) error {
 		}
 	}
 
-	l := lexer.New(d.r)
+	// The whole input is read up front, so that RawValue targets can be
+	// filled with the exact source of their value.
+	src, err := io.ReadAll(d.r)
+	if err != nil {
+		return fmt.Errorf("maml: %w", err)
+	}
+
+	l := lexer.New(bytes.NewReader(src))
 	parseOpts := []parser.Option{}
 	if o.parseComments {
 		parseOpts = append(parseOpts, parser.WithParseComments())
@@ -66,11 +77,12 @@ func (d *Decoder) Decode(out any) error {
 		return p.Errors()
 	}
 
-	return d.decodeDocument(doc, out, &o)
+	return d.decodeDocument(doc, src, out, &o)
 }
 
-// decodeDocument processes the options and maps the AST to a Go value.
-func (d *Decoder) decodeDocument(doc *ast.Document, v any, o *options) error {
+// decodeDocument processes the options and maps the AST to a Go value. src is
+// the source the document was parsed from.
+func (d *Decoder) decodeDocument(doc *ast.Document, src []byte, v any, o *options) error {
 	// If the target is an *ast.Document, just assign it.
 	if docPtr, ok := v.(**ast.Document); ok {
 		*docPtr = doc
@@ -92,13 +104,94 @@ func (d *Decoder) decodeDocument(doc *ast.Document, v any, o *options) error {
 	if !ok {
 		return fmt.Errorf("maml: document root is not a valid expression statement")
 	}
-	ds := &decodeState{depth: o.maxDepth, opts: o}
-	return ds.mapValue(stmt.Expression, rv.Elem())
+	ds := &decodeState{depth: o.maxDepth, opts: o, userOpts: d.opts, src: src}
+	if err := ds.mapValue(stmt.Expression, rv.Elem()); err != nil {
+		return err
+	}
+	if len(ds.errs) > 0 {
+		return ds.errs
+	}
+	return nil
 }
 
 type decodeState struct {
-	depth int
-	opts  *options
+	depth    int
+	opts     *options
+	userOpts []Option     // the options as given to the decoder, for DecodeContext
+	src      []byte       // source of the document, used for RawValue
+	path     ast.Path     // key path to the value being decoded
+	bytes    byteEncoding // encoding of byte slices in the current field
+	ordered  bool         // decode objects in interface values as OrderedMap
+	errs     DecodeErrors
+}
+
+// DecodeContext describes the state of the decoder when it calls
+// UnmarshalMAMLNode.
+type DecodeContext struct {
+	ds *decodeState
+}
+
+// Path returns the key path to the value being decoded. It is empty for the
+// root value.
+func (c *DecodeContext) Path() ast.Path {
+	return slices.Clone(c.ds.path)
+}
+
+// Options returns the options the decoder was created with, so that they can
+// be passed on to Unmarshal or NewDecoder.
+func (c *DecodeContext) Options() []Option {
+	return slices.Clone(c.ds.userOpts)
+}
+
+// Decode decodes node into the value pointed to by v, using the options and
+// the key path of the current decoder. Errors are reported as if the value
+// had been decoded in place, so that they carry the full key path.
+func (c *DecodeContext) Decode(node ast.Expression, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("maml: Decode(non-pointer %T or nil)", v)
+	}
+	if node == nil {
+		return fmt.Errorf("maml: Decode(nil node)")
+	}
+	return c.ds.mapValue(node, rv.Elem())
+}
+
+// pushKey appends an object key to the current path.
+func (ds *decodeState) pushKey(key string) {
+	ds.path = append(ds.path, ast.PathSegment{Key: key})
+}
+
+// pushIndex appends an array index to the current path.
+func (ds *decodeState) pushIndex(i int) {
+	ds.path = append(ds.path, ast.PathSegment{Index: i, IsIndex: true})
+}
+
+// pop removes the last segment from the current path.
+func (ds *decodeState) pop() {
+	ds.path = ds.path[:len(ds.path)-1]
+}
+
+// report returns err, or records it and returns nil when all errors are
+// being collected, so that decoding continues with the next value.
+func (ds *decodeState) report(err error) error {
+	if !ds.opts.allErrors {
+		return err
+	}
+	ds.errs = append(ds.errs, err)
+	return nil
+}
+
+// typeError reports an UnmarshalTypeError for a value of the given MAML kind
+// that cannot be stored in a Go value of type t.
+func (ds *decodeState) typeError(kind string, expr ast.Expression, t reflect.Type) error {
+	return ds.report(&UnmarshalTypeError{Value: kind, Type: t, Path: ds.path.String(), Pos: expr.Pos(), End: expr.End()})
+}
+
+// overflowError reports an OverflowError for a number that does not fit in a
+// Go value of type t.
+func (ds *decodeState) overflowError(kind, value string, expr ast.Expression, t reflect.Type) error {
+	return ds.report(&OverflowError{Value: value, Kind: kind, Type: t, Path: ds.path.String(), Pos: expr.Pos(), End: expr.End()})
 }
 
 func (ds *decodeState) mapValue(expr ast.Expression, rv reflect.Value) error { //nolint:gocyclo,funlen
@@ -108,6 +201,14 @@ func (ds *decodeState) mapValue(expr ast.Expression, rv reflect.Value) error { /
 	}
 	defer func() { ds.depth++ }()
 
+	// A RawValue receives the source of any value, including null.
+	if rv.Type() == rawValueType {
+		return ds.mapRawValue(expr, rv)
+	}
+	if rv.Type() == valueType {
+		return ds.mapDynamic(expr, rv)
+	}
+
 	if _, isNull := expr.(*ast.NullLiteral); isNull {
 		switch rv.Kind() {
 		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
@@ -116,7 +217,11 @@ func (ds *decodeState) mapValue(expr ast.Expression, rv reflect.Value) error { /
 		}
 	}
 
-	// Attempt to use a custom unmarshaler if available.
+	// Standard library types with a built-in decoding come first, then
+	// custom unmarshalers.
+	if handled, err := ds.mapStdType(expr, rv); err != nil || handled {
+		return err
+	}
 	handled, err := ds.tryCustomUnmarshal(expr, rv)
 	if err != nil {
 		return err
@@ -125,13 +230,29 @@ func (ds *decodeState) mapValue(expr ast.Expression, rv reflect.Value) error { /
 		return nil
 	}
 
-	for rv.Kind() == reflect.Pointer {
-		if rv.IsNil() {
-			rv.Set(reflect.New(rv.Type().Elem()))
+	if rv.Kind() == reflect.Pointer {
+		for rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		// The pointed-to value may implement an unmarshaler itself, as
+		// *big.Int does for encoding.TextUnmarshaler.
+		if handled, err := ds.mapStdType(expr, rv); err != nil || handled {
+			return err
+		}
+		if handled, err := ds.tryCustomUnmarshal(expr, rv); err != nil || handled {
+			return err
 		}
-		rv = rv.Elem()
 	}
 
+	switch rv.Type() {
+	case rawValueType:
+		return ds.mapRawValue(expr, rv)
+	case valueType:
+		return ds.mapDynamic(expr, rv)
+	}
 	if rv.Kind() == reflect.Interface {
 		return ds.mapInterface(expr, rv)
 	}
@@ -154,30 +275,34 @@ func (ds *decodeState) mapValue(expr ast.Expression, rv reflect.Value) error { /
 	case *ast.BooleanLiteral:
 		return ds.mapBool(node, rv)
 	case *ast.ArrayLiteral:
-		switch rv.Kind() {
-		case reflect.Slice:
+		switch {
+		case rv.Type() == orderedMapType:
+			return ds.typeError("array", node, rv.Type())
+		case rv.Kind() == reflect.Slice:
 			return ds.mapSlice(node, rv)
-		case reflect.Array:
+		case rv.Kind() == reflect.Array:
 			return ds.mapArray(node, rv)
 		default:
-			return fmt.Errorf("maml: cannot unmarshal array into Go value of type %s", rv.Type())
+			return ds.typeError("array", node, rv.Type())
 		}
 	case *ast.ObjectLiteral:
-		switch rv.Kind() {
-		case reflect.Struct:
+		switch {
+		case rv.Type() == orderedMapType:
+			return ds.mapOrderedMap(node, rv)
+		case rv.Kind() == reflect.Struct:
 			return ds.mapStruct(node, rv)
-		case reflect.Map:
+		case rv.Kind() == reflect.Map:
 			return ds.mapMap(node, rv)
 		default:
-			return fmt.Errorf("maml: cannot unmarshal object into Go value of type %s", rv.Type())
+			return ds.typeError("object", node, rv.Type())
 		}
 	default:
 		return fmt.Errorf("maml: mapping for AST node type %T not yet implemented", node)
 	}
 }
 
-// tryCustomUnmarshal attempts to use a custom unmarshaler (maml.Unmarshaler or
-// encoding.TextUnmarshaler) on the given reflect.Value. It returns true if a
+// tryCustomUnmarshal attempts to use a custom unmarshaler (maml.NodeUnmarshaler,
+// maml.Unmarshaler or encoding.TextUnmarshaler) on the given reflect.Value. It returns true if a
 // custom unmarshaler was found and used, in which case the caller should not
 // proceed with default unmarshaling.
 func (ds *decodeState) tryCustomUnmarshal(expr ast.Expression, rv reflect.Value) (bool, error) {
@@ -189,6 +314,20 @@ func (ds *decodeState) tryCustomUnmarshal(expr ast.Expression, rv reflect.Value)
 		return false, nil
 	}
 
+	// Check for maml.NodeUnmarshaler, which takes the node as is.
+	if u, ok := pv.Interface().(NodeUnmarshaler); ok {
+		if err := u.UnmarshalMAMLNode(expr, &DecodeContext{ds: ds}); err != nil {
+			// Errors from DecodeContext.Decode already describe the value.
+			if _, positioned := err.(interface {
+				Span() (token.Position, token.Position)
+			}); positioned {
+				return true, err
+			}
+			return true, ds.report(&UnmarshalerError{Type: pv.Type(), Err: err})
+		}
+		return true, nil
+	}
+
 	// Check for maml.Unmarshaler
 	if u, ok := pv.Interface().(Unmarshaler); ok {
 		var buf bytes.Buffer
@@ -198,7 +337,7 @@ func (ds *decodeState) tryCustomUnmarshal(expr ast.Expression, rv reflect.Value)
 			return true, fmt.Errorf("maml: failed to re-marshal node for custom unmarshaler: %w", err)
 		}
 		if err := u.UnmarshalMAML(buf.Bytes()); err != nil {
-			return true, &UnmarshalerError{Type: pv.Type(), Err: err}
+			return true, ds.report(&UnmarshalerError{Type: pv.Type(), Err: err})
 		}
 		return true, nil
 	}
@@ -210,8 +349,12 @@ func (ds *decodeState) tryCustomUnmarshal(expr ast.Expression, rv reflect.Value)
 			// TextUnmarshaler can only be used on string values.
 			return false, nil
 		}
-		if err := u.UnmarshalText([]byte(s.Value)); err != nil {
-			return true, &UnmarshalerError{Type: pv.Type(), Err: err}
+		text, err := ds.stringValue(s)
+		if err != nil {
+			return true, ds.report(err)
+		}
+		if err := u.UnmarshalText([]byte(text)); err != nil {
+			return true, ds.report(&UnmarshalerError{Type: pv.Type(), Err: err})
 		}
 		return true, nil
 	}
@@ -219,51 +362,121 @@ func (ds *decodeState) tryCustomUnmarshal(expr ast.Expression, rv reflect.Value)
 	return false, nil
 }
 
+// mapRawValue stores the source of expr in a RawValue. Nodes without a
+// source position are formatted compactly instead.
+func (ds *decodeState) mapRawValue(expr ast.Expression, rv reflect.Value) error {
+	start, end := expr.Pos(), expr.End()
+	if start.IsValid() && end.IsValid() && start.Offset <= end.Offset && end.Offset <= len(ds.src) {
+		rv.SetBytes(bytes.Clone(ds.src[start.Offset:end.Offset]))
+		return nil
+	}
+	var buf bytes.Buffer
+	compactIndent := 0
+	if err := newFormatter(&buf, &options{indent: &compactIndent}).format(expr); err != nil {
+		return fmt.Errorf("maml: failed to format raw value: %w", err)
+	}
+	rv.SetBytes(buf.Bytes())
+	return nil
+}
+
 func (ds *decodeState) mapString(s *ast.StringLiteral, rv reflect.Value) error {
-	if rv.Kind() != reflect.String {
-		return fmt.Errorf("maml: cannot unmarshal string into Go value of type %s", rv.Type())
+	if isByteSequence(rv.Type()) {
+		return ds.mapBytes(s, rv)
 	}
-	rv.SetString(s.Value)
+	if rv.Kind() != reflect.String || rv.Type() == numberType {
+		return ds.typeError("string", s, rv.Type())
+	}
+	v, err := ds.stringValue(s)
+	if err != nil {
+		return ds.report(err)
+	}
+	rv.SetString(v)
 	return nil
 }
 
 func (ds *decodeState) mapIdentifier(i *ast.Identifier, rv reflect.Value) error {
 	if rv.Kind() != reflect.String {
-		return fmt.Errorf("maml: cannot unmarshal identifier into Go value of type %s", rv.Type())
+		return ds.typeError("identifier", i, rv.Type())
 	}
 	rv.SetString(i.Value)
 	return nil
 }
 
 func (ds *decodeState) mapInt(i *ast.IntegerLiteral, rv reflect.Value) error {
+	if rv.Type() == numberType {
+		rv.SetString(i.String())
+		return nil
+	}
 	switch rv.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if rv.OverflowInt(i.Value) {
-			return fmt.Errorf("maml: integer value %d overflows Go value of type %s", i.Value, rv.Type())
+		if i.Big != nil || rv.OverflowInt(i.Value) {
+			return ds.overflowError("integer", i.BigInt().String(), i, rv.Type())
 		}
 		rv.SetInt(i.Value)
 		return nil
-	default:
-		return fmt.Errorf("maml: cannot unmarshal integer into Go value of type %s", rv.Type())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch {
+		case i.Big == nil && i.Value >= 0:
+			u = uint64(i.Value)
+		case i.Big != nil && i.Big.IsUint64():
+			u = i.Big.Uint64()
+		default:
+			return ds.overflowError("integer", i.BigInt().String(), i, rv.Type())
+		}
+		if rv.OverflowUint(u) {
+			return ds.overflowError("integer", i.BigInt().String(), i, rv.Type())
+		}
+		rv.SetUint(u)
+		return nil
+	case reflect.Struct:
+		switch rv.Type() {
+		case bigIntType:
+			rv.Addr().Interface().(*big.Int).Set(i.BigInt())
+			return nil
+		case bigFloatType:
+			n := i.BigInt()
+			f := rv.Addr().Interface().(*big.Float)
+			if f.Prec() == 0 {
+				// Keep every digit of the integer.
+				f.SetPrec(uint(max(64, n.BitLen())))
+			}
+			f.SetInt(n)
+			return nil
+		}
 	}
+	return ds.typeError("integer", i, rv.Type())
 }
 
 func (ds *decodeState) mapFloat(f *ast.FloatLiteral, rv reflect.Value) error {
+	if rv.Type() == numberType {
+		rv.SetString(f.String())
+		return nil
+	}
 	switch rv.Kind() {
 	case reflect.Float32, reflect.Float64:
 		if rv.OverflowFloat(f.Value) {
-			return fmt.Errorf("maml: float value %f overflows Go value of type %s", f.Value, rv.Type())
+			return ds.overflowError("float", fmt.Sprintf("%f", f.Value), f, rv.Type())
 		}
 		rv.SetFloat(f.Value)
 		return nil
-	default:
-		return fmt.Errorf("maml: cannot unmarshal float into Go value of type %s", rv.Type())
+	case reflect.Struct:
+		if rv.Type() == bigFloatType {
+			// Parse the literal rather than using Value, so that precision
+			// beyond a float64 is kept.
+			bf := rv.Addr().Interface().(*big.Float)
+			if _, ok := bf.SetString(f.Token.Literal); !ok {
+				bf.SetFloat64(f.Value)
+			}
+			return nil
+		}
 	}
+	return ds.typeError("float", f, rv.Type())
 }
 
 func (ds *decodeState) mapBool(b *ast.BooleanLiteral, rv reflect.Value) error {
 	if rv.Kind() != reflect.Bool {
-		return fmt.Errorf("maml: cannot unmarshal boolean into Go value of type %s", rv.Type())
+		return ds.typeError("boolean", b, rv.Type())
 	}
 	rv.SetBool(b.Value)
 	return nil
@@ -272,8 +485,11 @@ func (ds *decodeState) mapBool(b *ast.BooleanLiteral, rv reflect.Value) error {
 func (ds *decodeState) mapSlice(a *ast.ArrayLiteral, rv reflect.Value) error {
 	sliceType := rv.Type()
 	newSlice := reflect.MakeSlice(sliceType, len(a.Elements), len(a.Elements))
-	for i, elemAST := range a.Elements {
-		if err := ds.mapValue(elemAST, newSlice.Index(i)); err != nil {
+	for i, elem := range a.Elements {
+		ds.pushIndex(i)
+		err := ds.mapValue(elem.Value, newSlice.Index(i))
+		ds.pop()
+		if err != nil {
 			return err
 		}
 	}
@@ -283,10 +499,13 @@ func (ds *decodeState) mapSlice(a *ast.ArrayLiteral, rv reflect.Value) error {
 
 func (ds *decodeState) mapArray(a *ast.ArrayLiteral, rv reflect.Value) error {
 	if rv.Len() != len(a.Elements) {
-		return fmt.Errorf("maml: cannot unmarshal array of length %d into Go array of length %d", len(a.Elements), rv.Len())
+		return ds.report(&ArrayLengthError{Len: len(a.Elements), Type: rv.Type(), Path: ds.path.String(), Pos: a.Pos(), End: a.End()})
 	}
-	for i, elemAST := range a.Elements {
-		if err := ds.mapValue(elemAST, rv.Index(i)); err != nil {
+	for i, elem := range a.Elements {
+		ds.pushIndex(i)
+		err := ds.mapValue(elem.Value, rv.Index(i))
+		ds.pop()
+		if err != nil {
 			return err
 		}
 	}
@@ -308,23 +527,24 @@ func resolveMapKey(keyExpr ast.Expression) (string, error) {
 // findField finds the target field in a struct's cached fields.
 // It first attempts a case-sensitive match, then falls back to a
 // case-insensitive match.
-func findField(fields map[string]field, keyStr string) *field {
+func findField(fields *structFields, keyStr string) *field {
 	// Try a direct, case-sensitive match on the tag/field name.
-	if f, ok := fields[keyStr]; ok {
-		return &f
+	if f, ok := fields.byName[keyStr]; ok {
+		return f
 	}
 
 	// Fallback to a case-insensitive match pre-calculated in the cache.
-	if f, ok := fields[strings.ToLower(keyStr)]; ok {
-		return &f
+	if f, ok := fields.byName[strings.ToLower(keyStr)]; ok {
+		return f
 	}
 	return nil
 }
 
 func (ds *decodeState) mapMap(obj *ast.ObjectLiteral, rv reflect.Value) error {
 	mapType := rv.Type()
-	if mapType.Key().Kind() != reflect.String {
-		return fmt.Errorf("maml: cannot unmarshal object into map with non-string key type %s", mapType.Key())
+	keyType := mapType.Key()
+	if !isDecodableKey(keyType) {
+		return fmt.Errorf("maml: cannot unmarshal object into map with key type %s", keyType)
 	}
 	if rv.IsNil() {
 		rv.Set(reflect.MakeMap(mapType))
@@ -339,15 +559,71 @@ func (ds *decodeState) mapMap(obj *ast.ObjectLiteral, rv reflect.Value) error {
 		if err != nil {
 			return err
 		}
+		ds.pushKey(keyStr)
+		key, ok, err := ds.mapKey(pair.Key, keyStr, keyType)
+		if err != nil || !ok {
+			ds.pop()
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		newVal := reflect.New(elemType).Elem()
-		if err := ds.mapValue(pair.Value, newVal); err != nil {
+		err = ds.mapValue(pair.Value, newVal)
+		ds.pop()
+		if err != nil {
 			return err
 		}
-		rv.SetMapIndex(reflect.ValueOf(keyStr), newVal)
+		rv.SetMapIndex(key, newVal)
 	}
 	return nil
 }
 
+// isDecodableKey reports whether an object key can be decoded into a map key
+// of type t.
+func isDecodableKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// mapKey converts an object key to a map key of type t. Keys of a string kind
+// are used as is; other key types are parsed with their
+// encoding.TextUnmarshaler implementation, or as integers or booleans. It
+// reports false if the key could not be converted and the error was recorded
+// by the AllErrors option.
+func (ds *decodeState) mapKey(keyExpr ast.Expression, keyStr string, t reflect.Type) (reflect.Value, bool, error) {
+	key := reflect.New(t).Elem()
+	var err error
+	switch {
+	case t.Kind() == reflect.String:
+		key.SetString(keyStr)
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		u, _ := key.Addr().Interface().(encoding.TextUnmarshaler)
+		err = u.UnmarshalText([]byte(keyStr))
+	case t.Kind() == reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(keyStr)
+		key.SetBool(b)
+	case key.CanInt():
+		var n int64
+		n, err = strconv.ParseInt(keyStr, 10, t.Bits())
+		key.SetInt(n)
+	default:
+		var n uint64
+		n, err = strconv.ParseUint(keyStr, 10, t.Bits())
+		key.SetUint(n)
+	}
+	if err != nil {
+		return key, false, ds.parseError(fmt.Sprintf("key %q", keyStr), keyExpr, t, err)
+	}
+	return key, true, nil
+}
+
 // resolveFieldPath traverses the given field index path `idx` starting from `rv`.
 // It initializes any nil embedded pointers encountered along the path.
 // Returns the reflect.Value of the final field at the end of the path.
@@ -378,6 +654,9 @@ func (ds *decodeState) resolveFieldPath(rv reflect.Value, idx []int) (reflect.Va
 func (ds *decodeState) mapStruct(obj *ast.ObjectLiteral, rv reflect.Value) error {
 	fields := cachedFields(rv.Type())
 	seenFields := make(map[string]struct{})
+	setFields := make(map[*field]struct{})
+
+	callDefaulter(rv)
 
 	for _, pair := range obj.Pairs {
 		keyStr, err := resolveMapKey(pair.Key)
@@ -392,14 +671,28 @@ func (ds *decodeState) mapStruct(obj *ast.ObjectLiteral, rv reflect.Value) error
 			}
 
 			if finalFieldVal.IsValid() && finalFieldVal.CanSet() {
-				if err := ds.mapValue(pair.Value, finalFieldVal); err != nil {
+				ds.pushKey(keyStr)
+				outerBytes := ds.bytes
+				ds.bytes = targetField.bytes
+				err := ds.mapValue(pair.Value, finalFieldVal)
+				ds.bytes = outerBytes
+				ds.pop()
+				if err != nil {
 					return err
 				}
 				seenFields[keyStr] = struct{}{}
+				setFields[targetField] = struct{}{}
 			}
 		}
 	}
 
+	if err := ds.checkRequiredFields(obj, rv.Type(), fields, setFields); err != nil {
+		return err
+	}
+	if err := ds.applyDefaults(obj, rv, fields, setFields); err != nil {
+		return err
+	}
+
 	// Check for unknown fields if disallowUnknownFields is enabled
 	if ds.opts.disallowUnknownFields {
 		if err := ds.checkUnknownFields(obj, rv.Type(), seenFields); err != nil {
@@ -407,12 +700,55 @@ func (ds *decodeState) mapStruct(obj *ast.ObjectLiteral, rv reflect.Value) error
 		}
 	}
 
+	return ds.validate(obj, rv)
+}
+
+// checkRequiredFields reports the fields tagged `maml:",required"` that are
+// not in setFields.
+func (ds *decodeState) checkRequiredFields(obj *ast.ObjectLiteral, structType reflect.Type, fields *structFields, setFields map[*field]struct{}) error {
+	for _, f := range fields.list {
+		if _, ok := setFields[f]; !f.required || ok {
+			continue
+		}
+		err := ds.report(&MissingFieldError{
+			Field: f.name,
+			Type:  structType,
+			Path:  ds.path.String(),
+			Pos:   obj.Pos(),
+			End:   obj.End(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate calls the ValidateMAML method of the struct rv, decoded from obj,
+// if it implements Validator.
+func (ds *decodeState) validate(obj *ast.ObjectLiteral, rv reflect.Value) error {
+	if !rv.CanAddr() {
+		return nil
+	}
+	v, ok := rv.Addr().Interface().(Validator)
+	if !ok {
+		return nil
+	}
+	if err := v.ValidateMAML(); err != nil {
+		return ds.report(&ValidationError{
+			Type: rv.Type(),
+			Path: ds.path.String(),
+			Pos:  obj.Pos(),
+			End:  obj.End(),
+			Err:  err,
+		})
+	}
 	return nil
 }
 
 // checkUnknownFields iterates through the object literal's pairs and
-// returns an error if any field was not found in the seenFields map,
-// indicating an unknown field.
+// reports an UnknownFieldError for each field that was not found in the
+// seenFields map.
 func (ds *decodeState) checkUnknownFields(obj *ast.ObjectLiteral, structType reflect.Type, seenFields map[string]struct{}) error {
 	for _, pair := range obj.Pairs {
 		keyStr, err := resolveMapKey(pair.Key)
@@ -420,8 +756,20 @@ func (ds *decodeState) checkUnknownFields(obj *ast.ObjectLiteral, structType ref
 			// This should ideally not happen as resolveMapKey is called earlier
 			return err
 		}
-		if _, ok := seenFields[keyStr]; !ok {
-			return fmt.Errorf("maml: unknown field %q in type %s", keyStr, structType)
+		if _, ok := seenFields[keyStr]; ok {
+			continue
+		}
+		ds.pushKey(keyStr)
+		err = ds.report(&UnknownFieldError{
+			Field: keyStr,
+			Type:  structType,
+			Path:  ds.path.String(),
+			Pos:   pair.Key.Pos(),
+			End:   pair.Key.End(),
+		})
+		ds.pop()
+		if err != nil {
+			return err
 		}
 	}
 	return nil
@@ -432,7 +780,7 @@ func (ds *decodeState) mapInterface(expr ast.Expression, rv reflect.Value) error
 		return fmt.Errorf("maml: cannot unmarshal into non-empty interface %s", rv.Type())
 	}
 	var concreteVal reflect.Value
-	switch expr.(type) {
+	switch e := expr.(type) {
 	case *ast.Identifier:
 		var s string
 		concreteVal = reflect.ValueOf(&s).Elem()
@@ -440,11 +788,25 @@ func (ds *decodeState) mapInterface(expr ast.Expression, rv reflect.Value) error
 		var s string
 		concreteVal = reflect.ValueOf(&s).Elem()
 	case *ast.IntegerLiteral:
-		var i int64
-		concreteVal = reflect.ValueOf(&i).Elem()
+		switch {
+		case ds.opts.useNumber:
+			var n Number
+			concreteVal = reflect.ValueOf(&n).Elem()
+		case e.Big != nil:
+			var b *big.Int
+			concreteVal = reflect.ValueOf(&b).Elem()
+		default:
+			var i int64
+			concreteVal = reflect.ValueOf(&i).Elem()
+		}
 	case *ast.FloatLiteral:
-		var f float64
-		concreteVal = reflect.ValueOf(&f).Elem()
+		if ds.opts.useNumber {
+			var n Number
+			concreteVal = reflect.ValueOf(&n).Elem()
+		} else {
+			var f float64
+			concreteVal = reflect.ValueOf(&f).Elem()
+		}
 	case *ast.BooleanLiteral:
 		var b bool
 		concreteVal = reflect.ValueOf(&b).Elem()
@@ -452,8 +814,13 @@ func (ds *decodeState) mapInterface(expr ast.Expression, rv reflect.Value) error
 		var a []any
 		concreteVal = reflect.ValueOf(&a).Elem()
 	case *ast.ObjectLiteral:
-		var o map[string]any
-		concreteVal = reflect.ValueOf(&o).Elem()
+		if ds.ordered || ds.opts.useOrderedMap {
+			var o OrderedMap
+			concreteVal = reflect.ValueOf(&o).Elem()
+		} else {
+			var o map[string]any
+			concreteVal = reflect.ValueOf(&o).Elem()
+		}
 	case *ast.NullLiteral:
 		return nil
 	default:
@@ -466,19 +833,79 @@ func (ds *decodeState) mapInterface(expr ast.Expression, rv reflect.Value) error
 	return nil
 }
 
+// Types of the numbers with special handling in the decoder and the encoder.
+var (
+	numberType   = reflect.TypeFor[Number]()
+	rawValueType = reflect.TypeFor[RawValue]()
+	bigIntType   = reflect.TypeFor[big.Int]()
+	bigFloatType = reflect.TypeFor[big.Float]()
+
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// RawValue is a raw encoded MAML value. It can be used to delay decoding part
+// of a document, or to insert pre-encoded MAML into the output of Marshal.
+//
+// When decoding, a RawValue receives the exact source text of its value,
+// including any comments and formatting inside it. When encoding, the value
+// must be valid MAML; it is parsed and inserted into the output. A nil or
+// empty RawValue encodes as null.
+type RawValue []byte
+
+// A Number represents a MAML number literal, keeping its text exactly as it
+// was written. Decoding into a Number, or into an interface value with the
+// UseNumber option, preserves digits that an int64 or float64 would lose, and
+// the encoder writes a Number back verbatim.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// BigInt returns the number as a big.Int. It fails if the number is not an
+// integer.
+func (n Number) BigInt() (*big.Int, error) {
+	b, ok := new(big.Int).SetString(string(n), 10)
+	if !ok {
+		return nil, fmt.Errorf("maml: invalid integer %q", string(n))
+	}
+	return b, nil
+}
+
 // A field represents a single field in a struct.
 type field struct {
-	idx []int
+	name     string // the tag name, or the Go field name if untagged
+	idx      []int
+	typ      reflect.Type
+	bytes    byteEncoding
+	def      ast.Expression // default value from the struct tag, or nil
+	required bool
+}
+
+// structFields holds the fields of a struct type.
+type structFields struct {
+	byName map[string]*field // by name, with lower-case names for case-insensitive matching
+	list   []*field          // in declaration order
 }
 
-// fieldCache caches a map of struct field names to their properties.
-var fieldCache sync.Map // map[reflect.Type]map[string]field
+// fieldCache caches the fields of struct types.
+var fieldCache sync.Map // map[reflect.Type]*structFields
 
-// cachedFields returns a map of field names to field properties for the given type.
+// cachedFields returns the fields of the given struct type.
 // The result is cached to avoid repeated reflection work.
-func cachedFields(t reflect.Type) map[string]field { //nolint:gocognit
+func cachedFields(t reflect.Type) *structFields { //nolint:gocognit
 	if f, ok := fieldCache.Load(t); ok {
-		if fields, ok := f.(map[string]field); ok {
+		if fields, ok := f.(*structFields); ok {
 			return fields
 		}
 	}
@@ -486,60 +913,28 @@ func cachedFields(t reflect.Type) map[string]field { //nolint:gocognit
 	// fieldEntry stores information about a field found during traversal,
 	// including its depth for precedence resolution.
 	type fieldEntry struct {
-		f             field
-		name          string // The actual name (tag or field name)
-		depth         int    // Depth of embedding (0 for top-level)
-		originalField reflect.StructField
+		f     *field
+		name  string // The actual name (tag or field name)
+		depth int    // Depth of embedding (0 for top-level)
 	}
 
 	var collectedEntries []fieldEntry
-
-	var walkAndCollect func(currentType reflect.Type, currentIdx []int, currentDepth int)
-	walkAndCollect = func(currentType reflect.Type, currentIdx []int, currentDepth int) {
-		for i := 0; i < currentType.NumField(); i++ {
-			sf := currentType.Field(i)
-			// Create a new slice for fieldIdx to avoid appendAssign issues and ensure
-			// `currentIdx` is not modified by recursive calls using the same underlying array.
-			fieldIdx := make([]int, len(currentIdx)+1)
-			copy(fieldIdx, currentIdx)
-			fieldIdx[len(currentIdx)] = i
-
-			// Dereference embedded pointer types for recursion,
-			// but `fieldIdx` still points to the pointer if it was a pointer embed.
-			fieldType := sf.Type
-			if fieldType.Kind() == reflect.Pointer {
-				fieldType = fieldType.Elem()
-			}
-
-			if sf.Anonymous && fieldType.Kind() == reflect.Struct {
-				// Recurse into embedded structs, increment depth
-				walkAndCollect(fieldType, fieldIdx, currentDepth+1)
-				continue
-			}
-
-			// Skip unexported fields
-			if !sf.IsExported() {
-				continue
-			}
-
-			tag := sf.Tag.Get("maml")
-			// Skip fields with `maml:"-"` tag
-			if tag == "-" {
-				continue
-			}
-
-			actualField := field{idx: fieldIdx}
-			tagName := strings.Split(tag, ",")[0]
-
-			// Add entries for the tag name (if present) and the field name.
-			if tagName != "" {
-				collectedEntries = append(collectedEntries, fieldEntry{f: actualField, name: tagName, depth: currentDepth, originalField: sf})
-			}
-			collectedEntries = append(collectedEntries, fieldEntry{f: actualField, name: sf.Name, depth: currentDepth, originalField: sf})
+	visitFields(t, func(sf reflect.StructField, idx []int, depth int) {
+		tagName, opts := parseTag(sf.Tag.Get("maml"))
+		actualField := &field{name: sf.Name, idx: idx, typ: sf.Type, bytes: tagByteEncoding(opts), required: opts["required"]}
+		if tagName != "" {
+			actualField.name = tagName
+		}
+		if text, ok := tagDefault(sf); ok {
+			actualField.def = parseDefault(text, sf.Type)
 		}
-	}
 
-	walkAndCollect(t, nil, 0) // Start walking from the top-level type at depth 0
+		// Add entries for the tag name (if present) and the field name.
+		if tagName != "" {
+			collectedEntries = append(collectedEntries, fieldEntry{f: actualField, name: tagName, depth: depth})
+		}
+		collectedEntries = append(collectedEntries, fieldEntry{f: actualField, name: sf.Name, depth: depth})
+	})
 
 	// Now, filter `collectedEntries` to apply precedence rules.
 	// Fields at a shallower depth take precedence. If depths are equal,
@@ -560,26 +955,89 @@ func cachedFields(t reflect.Type) map[string]field { //nolint:gocognit
 		// (either shallower, or same depth but declared earlier due to traversal order).
 	}
 
-	finalFields := make(map[string]field)
+	finalFields := &structFields{byName: make(map[string]*field)}
 
 	// Populate finalFields, handling case-insensitive fallback as per original logic.
 	// For case-insensitive, if a case-sensitive match already exists (from precedenceMap),
 	// we do not overwrite it with a new lowercase entry.
 	for name, entry := range precedenceMap {
 		// Add the case-sensitive name first (or the chosen name from precedenceMap).
-		finalFields[name] = entry.f
+		finalFields.byName[name] = entry.f
 
 		// Now, consider the lowercase version for case-insensitive fallback.
 		lowerName := strings.ToLower(name)
-		if _, ok := finalFields[lowerName]; !ok {
+		if _, ok := finalFields.byName[lowerName]; !ok {
 			// Only add the lowercase version if it doesn't already exist.
 			// This means if "Name" was chosen (e.g. from a tag or field name),
 			// and "name" (lowercase of "Name") is used for lookup, it should map to the same field.
 			// This also respects if another field "name" (case-sensitive) was chosen.
-			finalFields[lowerName] = entry.f
+			finalFields.byName[lowerName] = entry.f
+		}
+	}
+
+	// List the fields that can be reached by one of their names.
+	for _, entry := range collectedEntries {
+		if precedenceMap[entry.name].f == entry.f && !slices.Contains(finalFields.list, entry.f) {
+			finalFields.list = append(finalFields.list, entry.f)
 		}
 	}
 
 	fieldCache.Store(t, finalFields)
 	return finalFields
 }
+
+// visitFields calls visit for every exported field of the struct type t that
+// is not tagged `maml:"-"`, in declaration order. The fields of anonymous
+// embedded structs, and pointers to structs, are visited in place of the
+// embedded field itself, with depth incremented for each level of embedding,
+// unless the embedded field has a name in its tag; it is then visited as an
+// ordinary field. idx is the index sequence of the field, as used by
+// reflect.Value.FieldByIndex.
+func visitFields(t reflect.Type, visit func(sf reflect.StructField, idx []int, depth int)) {
+	// visiting holds the embedded types on the current path, so that
+	// recursive embedding through pointers terminates.
+	visiting := map[reflect.Type]bool{t: true}
+
+	var walk func(currentType reflect.Type, currentIdx []int, currentDepth int)
+	walk = func(currentType reflect.Type, currentIdx []int, currentDepth int) {
+		for i := 0; i < currentType.NumField(); i++ {
+			sf := currentType.Field(i)
+			tag := sf.Tag.Get("maml")
+			// Skip fields with `maml:"-"` tag, including embedded structs.
+			if tag == "-" {
+				continue
+			}
+
+			// Create a new slice for fieldIdx to avoid appendAssign issues and ensure
+			// `currentIdx` is not modified by recursive calls using the same underlying array.
+			fieldIdx := make([]int, len(currentIdx)+1)
+			copy(fieldIdx, currentIdx)
+			fieldIdx[len(currentIdx)] = i
+
+			// Dereference embedded pointer types for recursion,
+			// but `fieldIdx` still points to the pointer if it was a pointer embed.
+			fieldType := sf.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+
+			if tagName, _ := parseTag(tag); sf.Anonymous && fieldType.Kind() == reflect.Struct && tagName == "" {
+				// Recurse into embedded structs, increment depth
+				if !visiting[fieldType] {
+					visiting[fieldType] = true
+					walk(fieldType, fieldIdx, currentDepth+1)
+					delete(visiting, fieldType)
+				}
+				continue
+			}
+
+			// Skip unexported fields
+			if !sf.IsExported() {
+				continue
+			}
+
+			visit(sf, fieldIdx, currentDepth)
+		}
+	}
+	walk(t, nil, 0) // Start walking from the top-level type at depth 0
+}
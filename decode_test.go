@@ -2,11 +2,17 @@ package maml_test
 
 import (
 	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/KimNorgaard/go-maml"
 	"github.com/KimNorgaard/go-maml/internal/testutil"
+	"github.com/KimNorgaard/go-maml/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -70,8 +76,11 @@ func TestUnmarshal(t *testing.T) {
 
 		var arr2 [2]int
 		err = maml.Unmarshal([]byte(`[1, 2, 3]`), &arr2)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "cannot unmarshal array of length 3 into Go array of length 2")
+		require.EqualError(t, err, "maml: cannot unmarshal array of length 3 into Go array of length 2 (at line 1, column 1)")
+		var lenErr *maml.ArrayLengthError
+		require.ErrorAs(t, err, &lenErr)
+		require.Equal(t, 3, lenErr.Len)
+		require.Equal(t, reflect.TypeFor[[2]int](), lenErr.Type)
 
 		var arr3 [4]int
 		err = maml.Unmarshal([]byte(`[1, 2, 3]`), &arr3)
@@ -121,7 +130,7 @@ func TestUnmarshal(t *testing.T) {
 		var i8 int8
 		err := maml.Unmarshal([]byte(`128`), &i8)
 		require.Error(t, err)
-		require.Contains(t, err.Error(), "overflows Go value of type int8")
+		require.Contains(t, err.Error(), "overflows Go value of type int8 (at line 1, column 1)")
 	})
 }
 
@@ -280,61 +289,61 @@ func TestUnmarshal_TypeMismatchErrors(t *testing.T) {
 			name:        "Object into String",
 			input:       `{ key: "value" }`,
 			target:      func() any { return new(string) },
-			expectedErr: "maml: cannot unmarshal object into Go value of type string",
+			expectedErr: "maml: cannot unmarshal object into Go value of type string (at line 1, column 1)",
 		},
 		{
 			name:        "Object into Int",
 			input:       `{ key: "value" }`,
 			target:      func() any { return new(int) },
-			expectedErr: "maml: cannot unmarshal object into Go value of type int",
+			expectedErr: "maml: cannot unmarshal object into Go value of type int (at line 1, column 1)",
 		},
 		{
 			name:        "Object into Slice",
 			input:       `{ key: "value" }`,
 			target:      func() any { return new([]string) },
-			expectedErr: "maml: cannot unmarshal object into Go value of type []string",
+			expectedErr: "maml: cannot unmarshal object into Go value of type []string (at line 1, column 1)",
 		},
 		{
 			name:        "Array into String",
 			input:       `[1, 2, 3]`,
 			target:      func() any { return new(string) },
-			expectedErr: "maml: cannot unmarshal array into Go value of type string",
+			expectedErr: "maml: cannot unmarshal array into Go value of type string (at line 1, column 1)",
 		},
 		{
 			name:        "Array into Int",
 			input:       `[1, 2, 3]`,
 			target:      func() any { return new(int) },
-			expectedErr: "maml: cannot unmarshal array into Go value of type int",
+			expectedErr: "maml: cannot unmarshal array into Go value of type int (at line 1, column 1)",
 		},
 		{
 			name:        "Array into Map",
 			input:       `[1, 2, 3]`,
 			target:      func() any { return new(map[string]int) },
-			expectedErr: "maml: cannot unmarshal array into Go value of type map[string]int",
+			expectedErr: "maml: cannot unmarshal array into Go value of type map[string]int (at line 1, column 1)",
 		},
 		{
 			name:        "String into Int",
 			input:       `"hello"`,
 			target:      func() any { return new(int) },
-			expectedErr: "maml: cannot unmarshal string into Go value of type int",
+			expectedErr: "maml: cannot unmarshal string into Go value of type int (at line 1, column 1)",
 		},
 		{
 			name:        "Integer into String",
 			input:       `123`,
 			target:      func() any { return new(string) },
-			expectedErr: "maml: cannot unmarshal integer into Go value of type string",
+			expectedErr: "maml: cannot unmarshal integer into Go value of type string (at line 1, column 1)",
 		},
 		{
 			name:        "Float into Int",
 			input:       `123.45`,
 			target:      func() any { return new(int) },
-			expectedErr: "maml: cannot unmarshal float into Go value of type int",
+			expectedErr: "maml: cannot unmarshal float into Go value of type int (at line 1, column 1)",
 		},
 		{
 			name:        "Boolean into Int",
 			input:       `true`,
 			target:      func() any { return new(int) },
-			expectedErr: "maml: cannot unmarshal boolean into Go value of type int",
+			expectedErr: "maml: cannot unmarshal boolean into Go value of type int (at line 1, column 1)",
 		},
 	}
 
@@ -348,17 +357,181 @@ func TestUnmarshal_TypeMismatchErrors(t *testing.T) {
 	}
 }
 
+func TestUnmarshal_TypeErrorPosition(t *testing.T) {
+	type spec struct {
+		Replicas int      `maml:"replicas"`
+		Ports    []int    `maml:"ports"`
+		Labels   []string `maml:"labels"`
+	}
+	type config struct {
+		Spec spec `maml:"spec"`
+	}
+
+	t.Run("Struct field", func(t *testing.T) {
+		input := "{\n  spec: {\n    replicas: \"three\"\n  }\n}"
+		var c config
+		err := maml.Unmarshal([]byte(input), &c)
+
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "string", typeErr.Value)
+		require.Equal(t, reflect.TypeFor[int](), typeErr.Type)
+		require.Equal(t, "spec.replicas", typeErr.Path)
+		require.Equal(t, token.Position{Offset: 26, Line: 3, Column: 15}, typeErr.Pos)
+		require.EqualError(t, err, "maml: cannot unmarshal string into Go value of type int (spec.replicas at line 3, column 15)")
+	})
+
+	t.Run("Array element", func(t *testing.T) {
+		var c config
+		err := maml.Unmarshal([]byte(`{spec: {ports: [80, 443, true]}}`), &c)
+
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "spec.ports[2]", typeErr.Path)
+		require.Equal(t, 26, typeErr.Pos.Column)
+	})
+
+	t.Run("Map value and quoted key", func(t *testing.T) {
+		var m map[string]map[string]int
+		err := maml.Unmarshal([]byte(`{"app.name": {x: [1]}}`), &m)
+
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "array", typeErr.Value)
+		require.Equal(t, `["app.name"].x`, typeErr.Path)
+	})
+}
+
+func TestUnmarshal_AllErrors(t *testing.T) {
+	type server struct {
+		Host string `maml:"host"`
+		Port int8   `maml:"port"`
+	}
+	type config struct {
+		Name    string         `maml:"name"`
+		Servers []server       `maml:"servers"`
+		Limits  map[string]int `maml:"limits"`
+		Debug   bool           `maml:"debug"`
+	}
+	input := `{
+  name: 42
+  servers: [
+    { host: "a", port: 80 }
+    { host: true, port: 300, tls: true }
+  ]
+  limits: { cpu: 2, memory: "1Gi" }
+  debug: true
+}`
+
+	t.Run("Stops at first error by default", func(t *testing.T) {
+		var c config
+		err := maml.Unmarshal([]byte(input), &c, maml.DisallowUnknownFields())
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "name", typeErr.Path)
+		var decodeErrs maml.DecodeErrors
+		require.False(t, errors.As(err, &decodeErrs))
+	})
+
+	t.Run("Collects every error", func(t *testing.T) {
+		var c config
+		err := maml.Unmarshal([]byte(input), &c, maml.DisallowUnknownFields(), maml.AllErrors())
+
+		var decodeErrs maml.DecodeErrors
+		require.ErrorAs(t, err, &decodeErrs)
+		require.Len(t, decodeErrs, 5)
+		require.Equal(t, `maml: 5 decode errors:
+	cannot unmarshal integer into Go value of type string (name at line 2, column 9)
+	cannot unmarshal boolean into Go value of type string (servers[1].host at line 5, column 13)
+	integer value 300 overflows Go value of type int8 (servers[1].port at line 5, column 25)
+	unknown field "tls" in type maml_test.server (servers[1].tls at line 5, column 30)
+	cannot unmarshal string into Go value of type int (limits.memory at line 7, column 29)`, err.Error())
+
+		var overflowErr *maml.OverflowError
+		require.ErrorAs(t, err, &overflowErr)
+		require.Equal(t, "300", overflowErr.Value)
+		require.Equal(t, reflect.TypeFor[int8](), overflowErr.Type)
+
+		var unknownErr *maml.UnknownFieldError
+		require.ErrorAs(t, err, &unknownErr)
+		require.Equal(t, "tls", unknownErr.Field)
+		require.Equal(t, "servers[1].tls", unknownErr.Path)
+
+		// Decoding continues past the failed values.
+		require.Equal(t, "a", c.Servers[0].Host)
+		require.Equal(t, int8(80), c.Servers[0].Port)
+		require.Equal(t, 2, c.Limits["cpu"])
+		require.True(t, c.Debug)
+	})
+
+	t.Run("Array length and unmarshaler errors", func(t *testing.T) {
+		var v struct {
+			Pair   [2]int               `maml:"pair"`
+			Custom CustomUnmarshalError `maml:"custom"`
+			Level  Level                `maml:"level"`
+			Name   string               `maml:"name"`
+		}
+		err := maml.Unmarshal([]byte(`{pair: [1, 2, 3], custom: 1, level: "loud", name: 2}`), &v, maml.AllErrors())
+
+		var decodeErrs maml.DecodeErrors
+		require.ErrorAs(t, err, &decodeErrs)
+		require.Len(t, decodeErrs, 4)
+		var lenErr *maml.ArrayLengthError
+		require.ErrorAs(t, decodeErrs[0], &lenErr)
+		require.Equal(t, "pair", lenErr.Path)
+		require.Equal(t, 8, lenErr.Pos.Column)
+		var unmarshalerErr *maml.UnmarshalerError
+		require.ErrorAs(t, decodeErrs[1], &unmarshalerErr)
+		require.ErrorAs(t, decodeErrs[2], &unmarshalerErr)
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, decodeErrs[3], &typeErr)
+		require.Equal(t, "name", typeErr.Path)
+	})
+
+	t.Run("Single error and no errors", func(t *testing.T) {
+		var c config
+		err := maml.Unmarshal([]byte(`{name: 1}`), &c, maml.AllErrors())
+		require.EqualError(t, err, "maml: cannot unmarshal integer into Go value of type string (name at line 1, column 8)")
+
+		require.NoError(t, maml.Unmarshal([]byte(`{name: "x"}`), &c, maml.AllErrors()))
+	})
+}
+
 func TestUnmarshal_OverflowErrors(t *testing.T) {
 	t.Run("Integer Overflow", func(t *testing.T) {
 		var i8 int8
 		err := maml.Unmarshal([]byte("128"), &i8)
 		require.Error(t, err)
-		require.EqualError(t, err, "maml: integer value 128 overflows Go value of type int8")
+		require.EqualError(t, err, "maml: integer value 128 overflows Go value of type int8 (at line 1, column 1)")
 
 		var i16 int16
 		err = maml.Unmarshal([]byte("32768"), &i16)
 		require.Error(t, err)
-		require.EqualError(t, err, "maml: integer value 32768 overflows Go value of type int16")
+		require.EqualError(t, err, "maml: integer value 32768 overflows Go value of type int16 (at line 1, column 1)")
+
+		var i64 int64
+		err = maml.Unmarshal([]byte("9223372036854775808"), &i64)
+		require.EqualError(t, err, "maml: integer value 9223372036854775808 overflows Go value of type int64 (at line 1, column 1)")
+	})
+
+	t.Run("Unsigned Integer Overflow", func(t *testing.T) {
+		var u16 uint16
+		err := maml.Unmarshal([]byte("65536"), &u16)
+		require.EqualError(t, err, "maml: integer value 65536 overflows Go value of type uint16 (at line 1, column 1)")
+
+		var u64 uint64
+		err = maml.Unmarshal([]byte("18446744073709551616"), &u64)
+		require.EqualError(t, err, "maml: integer value 18446744073709551616 overflows Go value of type uint64 (at line 1, column 1)")
+	})
+
+	t.Run("Negative Unsigned Integer", func(t *testing.T) {
+		var v struct{ Port uint16 }
+		err := maml.Unmarshal([]byte("{ Port: -1 }"), &v)
+		require.EqualError(t, err, "maml: integer value -1 overflows Go value of type uint16 (Port at line 1, column 9)")
+
+		var u64 uint64
+		err = maml.Unmarshal([]byte("-9223372036854775809"), &u64)
+		require.EqualError(t, err, "maml: integer value -9223372036854775809 overflows Go value of type uint64 (at line 1, column 1)")
 	})
 
 	t.Run("Float Overflow", func(t *testing.T) {
@@ -366,7 +539,276 @@ func TestUnmarshal_OverflowErrors(t *testing.T) {
 		// math.MaxFloat32 is approx 3.4e38.
 		err := maml.Unmarshal([]byte("3.5e38"), &f32)
 		require.Error(t, err)
-		require.EqualError(t, err, "maml: float value 350000000000000001565567347835409530880.000000 overflows Go value of type float32")
+		require.EqualError(t, err, "maml: float value 350000000000000001565567347835409530880.000000 overflows Go value of type float32 (at line 1, column 1)")
+	})
+}
+
+func TestUnmarshal_UnsignedIntegers(t *testing.T) {
+	var v struct {
+		U    uint
+		U8   uint8
+		U16  uint16
+		U32  uint32
+		U64  uint64
+		Uptr uintptr
+	}
+	input := `{
+		U: 1
+		U8: 255
+		U16: 65535
+		U32: 4294967295
+		U64: 18446744073709551615
+		Uptr: 42
+	}`
+	require.NoError(t, maml.Unmarshal([]byte(input), &v))
+	require.Equal(t, uint(1), v.U)
+	require.Equal(t, uint8(255), v.U8)
+	require.Equal(t, uint16(65535), v.U16)
+	require.Equal(t, uint32(4294967295), v.U32)
+	require.Equal(t, uint64(18446744073709551615), v.U64)
+	require.Equal(t, uintptr(42), v.Uptr)
+
+	// The encoder writes values beyond the int64 range, which must round-trip.
+	b, err := maml.Marshal(v)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "U64: 18446744073709551615")
+	var out struct {
+		U    uint
+		U8   uint8
+		U16  uint16
+		U32  uint32
+		U64  uint64
+		Uptr uintptr
+	}
+	require.NoError(t, maml.Unmarshal(b, &out))
+	require.Equal(t, v, out)
+}
+
+func TestUnmarshal_BigNumbers(t *testing.T) {
+	t.Run("big.Int", func(t *testing.T) {
+		var v struct {
+			Small *big.Int
+			Large *big.Int
+			Neg   big.Int
+			Text  *big.Int
+		}
+		input := `{
+			Small: 42
+			Large: 123456789012345678901234567890
+			Neg: -98765432109876543210
+			Text: "1000000000000000000000"
+		}`
+		require.NoError(t, maml.Unmarshal([]byte(input), &v))
+		require.Equal(t, "42", v.Small.String())
+		require.Equal(t, "123456789012345678901234567890", v.Large.String())
+		require.Equal(t, "-98765432109876543210", v.Neg.String())
+		require.Equal(t, "1000000000000000000000", v.Text.String())
+
+		err := maml.Unmarshal([]byte("1.5"), &v.Small)
+		require.EqualError(t, err, "maml: cannot unmarshal float into Go value of type big.Int (at line 1, column 1)")
+	})
+
+	t.Run("big.Float", func(t *testing.T) {
+		var v struct {
+			F *big.Float
+			I *big.Float
+		}
+		input := `{
+			F: 3.14159265358979323846264338327950288
+			I: 123456789012345678901234567890
+		}`
+		require.NoError(t, maml.Unmarshal([]byte(input), &v))
+		require.Equal(t, "3.141592653589793239", v.F.Text('g', 19))
+		require.Equal(t, "123456789012345678901234567890", v.I.Text('f', 0))
+
+		// A precision set by the caller is kept.
+		f := new(big.Float).SetPrec(200)
+		require.NoError(t, maml.Unmarshal([]byte("0.1"), f))
+		require.Equal(t, uint(200), f.Prec())
+		require.Equal(t, "0.1", f.Text('g', 50))
+	})
+
+	t.Run("Into interface", func(t *testing.T) {
+		var v any
+		require.NoError(t, maml.Unmarshal([]byte("[1, 123456789012345678901234567890]"), &v))
+		arr := v.([]any)
+		require.Equal(t, int64(1), arr[0])
+		require.Equal(t, "123456789012345678901234567890", arr[1].(*big.Int).String())
+	})
+
+	t.Run("Round trip", func(t *testing.T) {
+		type numbers struct {
+			I *big.Int
+			F big.Float
+			U uint64
+		}
+		in := numbers{I: new(big.Int).Lsh(big.NewInt(1), 100), U: math.MaxUint64}
+		in.F.SetPrec(100).SetFloat64(1.5)
+		in.F.Quo(&in.F, big.NewFloat(3).SetPrec(100))
+
+		b, err := maml.Marshal(in)
+		require.NoError(t, err)
+		require.Equal(t, "{\n  I: 1267650600228229401496703205376\n  F: 0.5\n  U: 18446744073709551615\n}", string(b))
+
+		var out numbers
+		require.NoError(t, maml.Unmarshal(b, &out))
+		require.Zero(t, in.I.Cmp(out.I))
+		require.Zero(t, in.F.Cmp(&out.F))
+		require.Equal(t, in.U, out.U)
+
+		_, err = maml.Marshal(new(big.Float).SetInf(false))
+		require.EqualError(t, err, "maml: cannot marshal infinite big.Float")
+	})
+}
+
+func TestNumber(t *testing.T) {
+	t.Run("UseNumber", func(t *testing.T) {
+		input := `{
+  id: 123456789012345678901234567890
+  price: 1.10
+  count: 3
+  ratio: -2.5e-3
+  name: "x"
+}`
+		var v any
+		require.NoError(t, maml.Unmarshal([]byte(input), &v, maml.UseNumber()))
+		obj := v.(map[string]any)
+		require.Equal(t, maml.Number("123456789012345678901234567890"), obj["id"])
+		require.Equal(t, maml.Number("1.10"), obj["price"])
+		require.Equal(t, maml.Number("3"), obj["count"])
+		require.Equal(t, maml.Number("-2.5e-3"), obj["ratio"])
+		require.Equal(t, "x", obj["name"])
+
+		// Without UseNumber the literal text is lost.
+		require.NoError(t, maml.Unmarshal([]byte(input), &v))
+		require.InDelta(t, 1.1, v.(map[string]any)["price"], 0)
+
+		// Numbers are written back verbatim.
+		require.NoError(t, maml.Unmarshal([]byte(input), &v, maml.UseNumber()))
+		b, err := maml.Marshal(v)
+		require.NoError(t, err)
+		require.Equal(t, "{\n  count: 3\n  id: 123456789012345678901234567890\n  name: \"x\"\n  price: 1.10\n  ratio: -2.5e-3\n}", string(b))
+	})
+
+	t.Run("Number fields", func(t *testing.T) {
+		var v struct {
+			A maml.Number
+			B *maml.Number
+			C []maml.Number
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{ A: 1.500, B: -0, C: [1, 2.0E+10] }`), &v))
+		require.Equal(t, maml.Number("1.500"), v.A)
+		require.Equal(t, maml.Number("-0"), *v.B)
+		require.Equal(t, []maml.Number{"1", "2.0E+10"}, v.C)
+
+		b, err := maml.Marshal(v, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, "{A:1.500,B:-0,C:[1,2.0E+10]}", string(b))
+
+		err = maml.Unmarshal([]byte(`{ A: "12" }`), &v)
+		require.EqualError(t, err, "maml: cannot unmarshal string into Go value of type maml.Number (A at line 1, column 6)")
+	})
+
+	t.Run("Accessors", func(t *testing.T) {
+		i, err := maml.Number("-42").Int64()
+		require.NoError(t, err)
+		require.Equal(t, int64(-42), i)
+
+		f, err := maml.Number("1.10").Float64()
+		require.NoError(t, err)
+		require.InDelta(t, 1.1, f, 0)
+
+		b, err := maml.Number("123456789012345678901234567890").BigInt()
+		require.NoError(t, err)
+		require.Equal(t, "123456789012345678901234567890", b.String())
+
+		_, err = maml.Number("1.5").Int64()
+		require.Error(t, err)
+		_, err = maml.Number("1.5").BigInt()
+		require.EqualError(t, err, `maml: invalid integer "1.5"`)
+		require.Equal(t, "1.5", maml.Number("1.5").String())
+	})
+
+	t.Run("Encoding", func(t *testing.T) {
+		b, err := maml.Marshal(maml.Number(""))
+		require.NoError(t, err)
+		require.Equal(t, "0", string(b))
+
+		_, err = maml.Marshal(maml.Number("12abc"))
+		require.EqualError(t, err, `maml: invalid number literal "12abc"`)
+		_, err = maml.Marshal(maml.Number("01"))
+		require.EqualError(t, err, `maml: invalid number literal "01"`)
+	})
+}
+
+func TestRawValue(t *testing.T) {
+	type plugin struct {
+		Type     string
+		Settings maml.RawValue
+	}
+	input := `{
+  plugins: [
+    {
+      type: "http"
+      settings: {
+        # listen on all interfaces
+        addr: ":8080",   timeout: 30
+      }
+    }
+    { type: "noop", settings: null }
+    { type: "text", settings: """
+multi
+line""" }
+  ]
+}`
+
+	t.Run("Decode captures the source", func(t *testing.T) {
+		var cfg struct{ Plugins []plugin }
+		require.NoError(t, maml.Unmarshal([]byte(input), &cfg))
+		require.Len(t, cfg.Plugins, 3)
+		require.Equal(t, "{\n        # listen on all interfaces\n        addr: \":8080\",   timeout: 30\n      }", string(cfg.Plugins[0].Settings))
+		require.Equal(t, "null", string(cfg.Plugins[1].Settings))
+		require.Equal(t, "\"\"\"\nmulti\nline\"\"\"", string(cfg.Plugins[2].Settings))
+
+		// The deferred value can be decoded once its type is known.
+		var http struct {
+			Addr    string
+			Timeout int
+		}
+		require.NoError(t, maml.Unmarshal(cfg.Plugins[0].Settings, &http))
+		require.Equal(t, ":8080", http.Addr)
+		require.Equal(t, 30, http.Timeout)
+	})
+
+	t.Run("Pointer and map targets", func(t *testing.T) {
+		var v struct {
+			P *maml.RawValue
+			N *maml.RawValue
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{ P: [1,  2], N: null }`), &v))
+		require.Equal(t, "[1,  2]", string(*v.P))
+		require.Nil(t, v.N)
+
+		var m map[string]maml.RawValue
+		require.NoError(t, maml.Unmarshal([]byte(`{ a: 1.50, b: "x" }`), &m))
+		require.Equal(t, map[string]maml.RawValue{"a": maml.RawValue("1.50"), "b": maml.RawValue(`"x"`)}, m)
+	})
+
+	t.Run("Encode splices the value", func(t *testing.T) {
+		out := struct {
+			Type     string
+			Settings maml.RawValue
+			Empty    maml.RawValue
+		}{
+			Type:     "http",
+			Settings: maml.RawValue("{addr: \":8080\" # the address\n timeout: 30}"),
+		}
+		b, err := maml.Marshal(out)
+		require.NoError(t, err)
+		require.Equal(t, "{\n  Type: \"http\"\n  Settings: {\n    addr: \":8080\" # the address\n    timeout: 30\n  }\n  Empty: null\n}", string(b))
+
+		_, err = maml.Marshal(maml.RawValue("{a: }"))
+		require.EqualError(t, err, "maml: invalid RawValue: no prefix parse function for } ('}') found")
 	})
 }
 
@@ -438,3 +880,61 @@ func BenchmarkDecode(b *testing.B) {
 		}
 	}
 }
+
+func TestUnmarshal_ExpandVariables(t *testing.T) {
+	env := map[string]string{"DB_HOST": "db.internal", "TIMEOUT": "5s"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	t.Run("Expands string values", func(t *testing.T) {
+		var v struct {
+			Host    string
+			Port    string
+			Timeout time.Duration
+			Extra   any
+		}
+		input := `{Host: "${DB_HOST}", Port: "${DB_PORT:-5432}", Timeout: "${TIMEOUT}", Extra: ["${DB_HOST}"]}`
+		require.NoError(t, maml.Unmarshal([]byte(input), &v, maml.ExpandVariables(lookup)))
+		require.Equal(t, "db.internal", v.Host)
+		require.Equal(t, "5432", v.Port)
+		require.Equal(t, 5*time.Second, v.Timeout)
+		require.Equal(t, []any{"db.internal"}, v.Extra)
+	})
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		var v string
+		require.NoError(t, maml.Unmarshal([]byte(`"${DB_HOST}"`), &v))
+		require.Equal(t, "${DB_HOST}", v)
+	})
+
+	t.Run("Errors report the position of the string", func(t *testing.T) {
+		var v struct{ Password string }
+		input := "{\n  Password: \"${DB_PASSWORD:?is required}\"\n}"
+		err := maml.Unmarshal([]byte(input), &v, maml.ExpandVariables(lookup))
+		var ie *maml.InterpolationError
+		require.ErrorAs(t, err, &ie)
+		require.Equal(t, "DB_PASSWORD", ie.Variable)
+		require.Equal(t, "Password", ie.Path)
+		require.Equal(t, token.Position{Offset: 14, Line: 2, Column: 13}, ie.Pos)
+		require.EqualError(t, err, "maml: variable DB_PASSWORD is not set: is required (Password at line 2, column 13)")
+	})
+
+	t.Run("Unset variable", func(t *testing.T) {
+		var v struct{ User string }
+		input := "{\n  User: \"${DB_USER}\"\n}"
+		err := maml.Unmarshal([]byte(input), &v, maml.ExpandVariables(lookup))
+		var ie *maml.InterpolationError
+		require.ErrorAs(t, err, &ie)
+		require.Equal(t, "DB_USER", ie.Variable)
+		require.Equal(t, token.Position{Offset: 10, Line: 2, Column: 9}, ie.Pos)
+		require.EqualError(t, err, "maml: variable DB_USER is not set (User at line 2, column 9)")
+	})
+
+	t.Run("Nil lookup", func(t *testing.T) {
+		var v string
+		err := maml.Unmarshal([]byte(`""`), &v, maml.ExpandVariables(nil))
+		require.EqualError(t, err, "maml: lookup function must not be nil")
+	})
+}
@@ -0,0 +1,146 @@
+package maml
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/KimNorgaard/go-maml/internal/lexer"
+	"github.com/KimNorgaard/go-maml/internal/parser"
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+// tagDefault returns the default value of a struct field, given either by a
+// `default:"..."` tag or by a `maml:",default=..."` option.
+func tagDefault(sf reflect.StructField) (string, bool) {
+	if text, ok := sf.Tag.Lookup("default"); ok {
+		return text, true
+	}
+	tag := sf.Tag.Get("maml")
+	if i := strings.Index(tag, ",default="); i >= 0 {
+		return tag[i+len(",default="):], true
+	}
+	return "", false
+}
+
+// parseDefault parses the default value of a field of type t as a MAML value.
+// Text that is not a valid MAML value, and unquoted defaults of string
+// fields, are used as strings, so that `default:"localhost"` and
+// `default:"30s"` work as expected.
+func parseDefault(text string, t reflect.Type) ast.Expression {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	p := parser.New(lexer.New(strings.NewReader(text)))
+	expr := p.Parse().Root()
+	if len(p.Errors()) > 0 || expr == nil {
+		return ast.NewString(text)
+	}
+	if _, isString := expr.(*ast.StringLiteral); !isString && t.Kind() == reflect.String && t != numberType {
+		return ast.NewString(text)
+	}
+	clearPositions(expr)
+	return expr
+}
+
+// clearPositions removes the source positions from a parsed default value,
+// as they refer to the struct tag rather than to the document.
+func clearPositions(expr ast.Expression) {
+	clearToken := func(tok *token.Token) {
+		tok.Line, tok.Column, tok.Offset, tok.End = 0, 0, 0, token.Position{}
+	}
+	switch n := expr.(type) {
+	case *ast.Identifier:
+		clearToken(&n.Token)
+	case *ast.StringLiteral:
+		clearToken(&n.Token)
+	case *ast.IntegerLiteral:
+		clearToken(&n.Token)
+	case *ast.FloatLiteral:
+		clearToken(&n.Token)
+	case *ast.BooleanLiteral:
+		clearToken(&n.Token)
+	case *ast.NullLiteral:
+		clearToken(&n.Token)
+	case *ast.ArrayLiteral:
+		clearToken(&n.Token)
+		clearToken(&n.EndToken)
+		for _, el := range n.Elements {
+			clearPositions(el.Value)
+		}
+	case *ast.ObjectLiteral:
+		clearToken(&n.Token)
+		clearToken(&n.EndToken)
+		for _, pair := range n.Pairs {
+			clearToken(&pair.Token)
+			clearPositions(pair.Key)
+			clearPositions(pair.Value)
+		}
+	}
+}
+
+// callDefaulter calls the SetDefaults method of rv, if it implements
+// Defaulter.
+func callDefaulter(rv reflect.Value) {
+	if !rv.CanAddr() {
+		return
+	}
+	if d, ok := rv.Addr().Interface().(Defaulter); ok {
+		d.SetDefaults()
+	}
+}
+
+// applyDefaults sets the fields of the struct rv, decoded from obj, that are
+// not in set to their default values. Struct fields without a default are
+// decoded as if they had been given as an empty object: they get the
+// defaults of their own fields, and their required fields and ValidateMAML
+// method are checked, reported at the position of obj.
+func (ds *decodeState) applyDefaults(obj *ast.ObjectLiteral, rv reflect.Value, fields *structFields, set map[*field]struct{}) error {
+	for _, f := range fields.list {
+		if _, ok := set[f]; ok {
+			continue
+		}
+		switch {
+		case f.def != nil:
+			fv, err := ds.resolveFieldPath(rv, f.idx)
+			if err != nil {
+				return err
+			}
+			ds.pushKey(f.name)
+			outerBytes := ds.bytes
+			ds.bytes = f.bytes
+			err = ds.mapValue(f.def, fv)
+			ds.bytes = outerBytes
+			ds.pop()
+			if err != nil {
+				return err
+			}
+		case f.typ.Kind() == reflect.Struct && !isStdType(f.typ):
+			fv, ok := fieldByIndex(rv, f.idx)
+			if !ok {
+				continue
+			}
+			ds.pushKey(f.name)
+			err := ds.decodeAbsentStruct(obj, fv)
+			ds.pop()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeAbsentStruct fills in the struct rv, whose key is missing from obj,
+// as mapStruct would for an empty object.
+func (ds *decodeState) decodeAbsentStruct(obj *ast.ObjectLiteral, rv reflect.Value) error {
+	fields := cachedFields(rv.Type())
+	callDefaulter(rv)
+	if err := ds.checkRequiredFields(obj, rv.Type(), fields, nil); err != nil {
+		return err
+	}
+	if err := ds.applyDefaults(obj, rv, fields, nil); err != nil {
+		return err
+	}
+	return ds.validate(obj, rv)
+}
@@ -0,0 +1,109 @@
+package maml_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/stretchr/testify/require"
+)
+
+type serverDefaults struct {
+	Host    string        `maml:"host" default:"localhost"`
+	Port    int           `maml:"port,default=8080"`
+	Timeout time.Duration `maml:"timeout" default:"30s"`
+	Tags    []string      `maml:"tags,omitempty,default=[\"a\", \"b\"]"`
+	Limits  struct {
+		Max int `maml:"max" default:"10"`
+	} `maml:"limits"`
+	Debug *bool  `maml:"debug" default:"true"`
+	Name  string `maml:"name"`
+}
+
+func TestDefaults(t *testing.T) {
+	t.Run("Missing fields get their defaults", func(t *testing.T) {
+		var v serverDefaults
+		require.NoError(t, maml.Unmarshal([]byte(`{name: "api"}`), &v))
+		require.Equal(t, "localhost", v.Host)
+		require.Equal(t, 8080, v.Port)
+		require.Equal(t, 30*time.Second, v.Timeout)
+		require.Equal(t, []string{"a", "b"}, v.Tags)
+		require.Equal(t, 10, v.Limits.Max)
+		require.NotNil(t, v.Debug)
+		require.True(t, *v.Debug)
+		require.Equal(t, "api", v.Name)
+	})
+
+	t.Run("Document values take precedence", func(t *testing.T) {
+		var v serverDefaults
+		input := `{host: "example.com", port: 0, tags: [], limits: {max: 3}, debug: null}`
+		require.NoError(t, maml.Unmarshal([]byte(input), &v))
+		require.Equal(t, "example.com", v.Host)
+		require.Equal(t, 0, v.Port)
+		require.Equal(t, []string{}, v.Tags)
+		require.Equal(t, 3, v.Limits.Max)
+		require.Nil(t, v.Debug)
+	})
+
+	t.Run("Nested structs in slices and maps", func(t *testing.T) {
+		var v struct {
+			List []serverDefaults
+			ByID map[int]serverDefaults
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{List: [{port: 1}], ByID: {7: {}}}`), &v))
+		require.Equal(t, 1, v.List[0].Port)
+		require.Equal(t, "localhost", v.List[0].Host)
+		require.Equal(t, 8080, v.ByID[7].Port)
+	})
+
+	t.Run("Invalid default", func(t *testing.T) {
+		var v struct {
+			Server struct {
+				Port int `default:"eighty"`
+			}
+		}
+		err := maml.Unmarshal([]byte(`{}`), &v)
+		require.EqualError(t, err, "maml: cannot unmarshal identifier into Go value of type int (Server.Port)")
+	})
+}
+
+type defaulterConfig struct {
+	Level   string
+	Retries int `default:"3"`
+	Inner   defaulterInner
+	Items   []*defaulterInner
+	calls   int
+}
+
+func (c *defaulterConfig) SetDefaults() {
+	c.calls++
+	c.Level = "info"
+	c.Retries = 1
+}
+
+type defaulterInner struct {
+	Enabled bool
+}
+
+func (i *defaulterInner) SetDefaults() {
+	i.Enabled = true
+}
+
+func TestDefaulter(t *testing.T) {
+	t.Run("Called before fields are populated", func(t *testing.T) {
+		var v defaulterConfig
+		require.NoError(t, maml.Unmarshal([]byte(`{Level: "debug", Items: [{}, {Enabled: false}]}`), &v))
+		require.Equal(t, 1, v.calls)
+		require.Equal(t, "debug", v.Level)
+		require.Equal(t, 3, v.Retries, "tag defaults take precedence")
+		require.True(t, v.Inner.Enabled, "missing struct fields are defaulted")
+		require.True(t, v.Items[0].Enabled)
+		require.False(t, v.Items[1].Enabled)
+	})
+
+	t.Run("Map values", func(t *testing.T) {
+		var v map[string]defaulterInner
+		require.NoError(t, maml.Unmarshal([]byte(`{a: {}}`), &v))
+		require.True(t, v["a"].Enabled)
+	})
+}
@@ -47,7 +47,11 @@ Example of a comment-preserving round-trip:
 		// handle error
 	}
 
-	// The 'doc' can now be inspected or modified.
+	// The 'doc' can now be inspected or modified using the types and
+	// constructors of the ast package.
+	if obj, ok := doc.Root().(*ast.ObjectLiteral); ok {
+		obj.Pairs[0].Value = ast.NewString("MAML v2")
+	}
 
 	// Marshal the AST back to bytes, preserving the comment.
 	// Use functional options like Indent for formatting.
@@ -57,7 +61,12 @@ Example of a comment-preserving round-trip:
 	}
 	// output will contain the original comment and structure.
 
+A document can also be updated from a Go value with MarshalInto, which only
+changes the values and keys that differ, so that the comments and layout of
+everything else are kept.
+
 Customization is available via struct field tags (e.g., `maml:"key,omitempty"`)
-and by implementing the maml.Marshaler and maml.Unmarshaler interfaces.
+and by implementing the maml.Marshaler and maml.Unmarshaler interfaces, or
+their AST-based counterparts maml.NodeMarshaler and maml.NodeUnmarshaler.
 */
 package maml
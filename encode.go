@@ -2,18 +2,22 @@ package maml
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/KimNorgaard/go-maml/internal/lexer"
 	"github.com/KimNorgaard/go-maml/internal/parser"
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/token"
 )
 
 // Encoder writes MAML values to an output stream.
@@ -53,10 +57,10 @@ func (e *Encoder) Encode(in any) error {
 		return f.format(node)
 	}
 
-	es := &encodeState{seen: make(map[uintptr]struct{})}
+	es := &encodeState{seen: make(map[uintptr]struct{}), opts: &o}
 	node, err := es.marshalValue(reflect.ValueOf(in))
 	if err != nil {
-		return fmt.Errorf("maml: %w", err)
+		return err
 	}
 
 	f := newFormatter(e.w, &o)
@@ -66,6 +70,48 @@ func (e *Encoder) Encode(in any) error {
 type encodeState struct {
 	// Keep track of pointers seen so far.
 	seen map[uintptr]struct{}
+	opts *options
+	// The encoding of byte slices in the current struct field.
+	bytes byteEncoding
+	// The struct types of the encoded objects, if recorded for MarshalInto.
+	structTypes map[*ast.ObjectLiteral]reflect.Type
+}
+
+// tryCustomMarshal uses the NodeMarshaler, Marshaler or
+// encoding.TextMarshaler implementation of v, if any. It reports whether one was found.
+func (e *encodeState) tryCustomMarshal(v reflect.Value) (ast.Node, bool, error) {
+	if v.Type().NumMethod() == 0 || !v.CanInterface() {
+		return nil, false, nil
+	}
+	switch u := v.Interface().(type) {
+	case NodeMarshaler:
+		node, err := e.marshalCustomNode(v, u)
+		return node, true, err
+	case Marshaler:
+		node, err := e.marshalCustom(v, u)
+		return node, true, err
+	case encoding.TextMarshaler:
+		text, err := u.MarshalText()
+		if err != nil {
+			return nil, true, &MarshalerError{Type: v.Type(), Err: err}
+		}
+		return ast.NewString(string(text)), true, nil
+	}
+	return nil, false, nil
+}
+
+func (e *encodeState) marshalCustomNode(v reflect.Value, u NodeMarshaler) (ast.Node, error) {
+	node, err := u.MarshalMAMLNode()
+	if err != nil {
+		return nil, &MarshalerError{Type: v.Type(), Err: err}
+	}
+	if node == nil {
+		return ast.NewNull(), nil
+	}
+	if bad, ok := node.(*ast.BadExpression); ok {
+		return nil, &MarshalerError{Type: v.Type(), Err: fmt.Errorf("invalid MAML output: %s", bad)}
+	}
+	return node, nil
 }
 
 func (e *encodeState) marshalCustom(v reflect.Value, u Marshaler) (ast.Node, error) {
@@ -93,7 +139,7 @@ func (e *encodeState) marshalCustom(v reflect.Value, u Marshaler) (ast.Node, err
 
 	if len(doc.Statements) == 0 {
 		// An empty document from a custom marshaler is treated as a null value.
-		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+		return ast.NewNull(), nil
 	}
 
 	// This check is defensive. The current parser implementation is designed
@@ -118,7 +164,11 @@ func (e *encodeState) marshalCustom(v reflect.Value, u Marshaler) (ast.Node, err
 }
 
 // parseTag splits a maml struct tag into its name and options.
+// A default option must come last, as its value may contain commas.
 func parseTag(tag string) (string, map[string]bool) {
+	if i := strings.Index(tag, ",default="); i >= 0 {
+		tag = tag[:i]
+	}
 	parts := strings.Split(tag, ",")
 	name := parts[0]
 	options := make(map[string]bool)
@@ -147,54 +197,23 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
-// isBareKey checks if a string can be used as a bare key in an object.
-// Bare keys can be identifiers or numbers, but not keywords.
-func isBareKey(s string) bool {
-	if s == "" {
-		return false
-	}
-
-	// Keywords must be quoted.
-	if token.LookupIdent(s) != token.IDENT {
-		return false
-	}
-
-	// If it can be parsed as a number, it can be a bare key.
-	if _, ok := lexer.ParseAsNumber(s); ok {
-		return true
-	}
-
-	// Otherwise, it must be a valid identifier.
-	// Must not start with a hyphen (unless it's a number, handled above).
-	if s[0] == '-' {
-		return false
+func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint:gocyclo
+	if !v.IsValid() {
+		return ast.NewNull(), nil
 	}
 
-	for _, r := range s {
-		if !isIdentifierChar(r) {
-			return false
-		}
+	// Standard library types with a built-in encoding come first, so that
+	// for example a time.Time is written in the configured layout.
+	if isStdType(v.Type()) {
+		return e.marshalStdType(v)
 	}
-
-	return true
-}
-
-// isIdentifierChar checks if a rune is a valid character for a MAML identifier.
-func isIdentifierChar(r rune) bool {
-	return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') ||
-		('0' <= r && r <= '9') || r == '_' || r == '-'
-}
-
-func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint:gocyclo
-	if !v.IsValid() {
-		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+	if v.Kind() == reflect.Pointer && isStdType(v.Type().Elem()) {
+		return e.marshalPointer(v)
 	}
 
-	// Check for custom Marshaler implementation first.
-	if v.Type().NumMethod() > 0 && v.CanInterface() {
-		if u, ok := v.Interface().(Marshaler); ok {
-			return e.marshalCustom(v, u)
-		}
+	// Check for custom marshaler implementations next.
+	if node, ok, err := e.tryCustomMarshal(v); ok {
+		return node, err
 	}
 	if v.Kind() != reflect.Pointer {
 		var pv reflect.Value
@@ -204,10 +223,8 @@ func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint
 			pv = reflect.New(v.Type())
 			pv.Elem().Set(v)
 		}
-		if pv.Type().NumMethod() > 0 && pv.CanInterface() {
-			if u, ok := pv.Interface().(Marshaler); ok {
-				return e.marshalCustom(pv, u)
-			}
+		if node, ok, err := e.tryCustomMarshal(pv); ok {
+			return node, err
 		}
 	}
 
@@ -217,6 +234,9 @@ func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint
 	case reflect.Interface:
 		return e.marshalInterface(v)
 	case reflect.String:
+		if v.Type() == numberType {
+			return e.marshalNumber(v)
+		}
 		return e.marshalString(v)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return e.marshalInt(v)
@@ -227,15 +247,27 @@ func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint
 	case reflect.Bool:
 		return e.marshalBool(v)
 	case reflect.Slice, reflect.Array:
+		if v.Type() == rawValueType {
+			return e.marshalRawValue(v)
+		}
+		if v.Type() == orderedMapType {
+			return e.marshalOrderedMap(v)
+		}
+		if isByteSequence(v.Type()) {
+			return e.marshalBytes(v)
+		}
 		return e.marshalSlice(v)
 	case reflect.Map:
 		return e.marshalMap(v)
 	case reflect.Struct:
+		if v.Type() == valueType {
+			return e.marshalDynamic(v)
+		}
 		return e.marshalStruct(v)
 	default:
 		// nil can be a valid value for some kinds (e.g. chan, func, map, ptr, slice)
 		if !v.IsValid() || v.IsZero() {
-			return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+			return ast.NewNull(), nil
 		}
 		return nil, fmt.Errorf("maml: unsupported type for marshaling: %s", v.Type())
 	}
@@ -243,7 +275,7 @@ func (e *encodeState) marshalValue(v reflect.Value) (ast.Node, error) { //nolint
 
 func (e *encodeState) marshalPointer(v reflect.Value) (ast.Node, error) {
 	if v.IsNil() {
-		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+		return ast.NewNull(), nil
 	}
 	ptr := v.Pointer()
 	if _, ok := e.seen[ptr]; ok {
@@ -257,56 +289,110 @@ func (e *encodeState) marshalPointer(v reflect.Value) (ast.Node, error) {
 
 func (e *encodeState) marshalInterface(v reflect.Value) (ast.Node, error) {
 	if v.IsNil() {
-		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+		return ast.NewNull(), nil
 	}
 	return e.marshalValue(v.Elem())
 }
 
 func (e *encodeState) marshalString(v reflect.Value) (ast.Node, error) {
+	return ast.NewString(v.String()), nil
+}
+
+// marshalNumber writes the literal of a Number verbatim. The empty Number is
+// written as 0.
+func (e *encodeState) marshalNumber(v reflect.Value) (ast.Node, error) {
 	lit := v.String()
-	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: lit}, Value: lit}, nil
+	if lit == "" {
+		return ast.NewInteger(0), nil
+	}
+	typ, ok := lexer.ParseAsNumber(lit)
+	if !ok {
+		return nil, fmt.Errorf("maml: invalid number literal %q", lit)
+	}
+	if typ == token.FLOAT {
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, fmt.Errorf("maml: invalid number literal %q: %w", lit, err)
+		}
+		return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: lit}, Value: f}, nil
+	}
+	i, ok := new(big.Int).SetString(lit, 10)
+	if !ok {
+		return nil, fmt.Errorf("maml: invalid number literal %q", lit)
+	}
+	node := ast.NewBigInteger(i)
+	node.Token.Literal = lit
+	return node, nil
+}
+
+// marshalRawValue parses a RawValue so that it can be inserted into the
+// output. Comments inside the value are kept.
+func (e *encodeState) marshalRawValue(v reflect.Value) (ast.Node, error) {
+	if len(bytes.TrimSpace(v.Bytes())) == 0 {
+		return ast.NewNull(), nil
+	}
+	p := parser.New(lexer.New(bytes.NewReader(v.Bytes())), parser.WithParseComments())
+	doc := p.Parse()
+	if len(p.Errors()) > 0 {
+		var errs []string
+		for _, err := range p.Errors() {
+			errs = append(errs, err.Message)
+		}
+		return nil, fmt.Errorf("maml: invalid RawValue: %s", strings.Join(errs, "; "))
+	}
+	if doc.Root() == nil {
+		return ast.NewNull(), nil
+	}
+	return doc.Root(), nil
 }
 
 func (e *encodeState) marshalInt(v reflect.Value) (ast.Node, error) {
-	val := v.Int()
-	lit := fmt.Sprintf("%d", val)
-	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit}, Value: val}, nil
+	return ast.NewInteger(v.Int()), nil
 }
 
 func (e *encodeState) marshalUint(v reflect.Value) (ast.Node, error) {
 	val := v.Uint()
 	if val > math.MaxInt64 {
-		return nil, fmt.Errorf("maml: cannot marshal uint64 %d into MAML (overflows int64)", val)
+		return ast.NewBigInteger(new(big.Int).SetUint64(val)), nil
 	}
-	lit := fmt.Sprintf("%d", val)
-	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: lit}, Value: int64(val)}, nil
+	return ast.NewInteger(int64(val)), nil
 }
 
-func (e *encodeState) marshalFloat(v reflect.Value) (ast.Node, error) {
-	val := v.Float()
-	lit := strconv.FormatFloat(val, 'g', -1, 64)
-	// If the formatted string doesn't contain a decimal or an exponent, add .0
-	// to ensure it's treated as a float.
-	if !strings.ContainsAny(lit, ".eE") {
-		lit += ".0"
+func (e *encodeState) marshalBigInt(v reflect.Value) (ast.Node, error) {
+	return ast.NewBigInteger(addressable(v).Interface().(*big.Int)), nil
+}
+
+func (e *encodeState) marshalBigFloat(v reflect.Value) (ast.Node, error) {
+	f := addressable(v).Interface().(*big.Float)
+	if f.IsInf() {
+		return nil, fmt.Errorf("maml: cannot marshal infinite big.Float")
 	}
-	return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: lit}, Value: val}, nil
+	return ast.NewBigFloat(f), nil
 }
 
-func (e *encodeState) marshalBool(v reflect.Value) (ast.Node, error) {
-	val := v.Bool()
-	lit := fmt.Sprintf("%t", val)
-	tokType := token.FALSE
-	if val {
-		tokType = token.TRUE
+// addressable returns a pointer to the value v, copying v if it is not
+// addressable.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v.Addr()
 	}
-	return &ast.BooleanLiteral{Token: token.Token{Type: tokType, Literal: lit}, Value: val}, nil
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	return pv
+}
+
+func (e *encodeState) marshalFloat(v reflect.Value) (ast.Node, error) {
+	return ast.NewFloat(v.Float()), nil
+}
+
+func (e *encodeState) marshalBool(v reflect.Value) (ast.Node, error) {
+	return ast.NewBoolean(v.Bool()), nil
 }
 
 func (e *encodeState) marshalSlice(v reflect.Value) (ast.Node, error) {
 	if v.Kind() == reflect.Slice {
 		if v.IsNil() {
-			return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+			return ast.NewNull(), nil
 		}
 		ptr := v.Pointer()
 		if _, ok := e.seen[ptr]; ok {
@@ -328,15 +414,12 @@ func (e *encodeState) marshalSlice(v reflect.Value) (ast.Node, error) {
 		}
 		elements[i] = elemExpr
 	}
-	return &ast.ArrayLiteral{
-		Token:    token.Token{Type: token.LBRACK, Literal: "["},
-		Elements: elements,
-	}, nil
+	return ast.NewArray(elements...), nil
 }
 
 func (e *encodeState) marshalMap(v reflect.Value) (ast.Node, error) {
 	if v.IsNil() {
-		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}, nil
+		return ast.NewNull(), nil
 	}
 	ptr := v.Pointer()
 	if _, ok := e.seen[ptr]; ok {
@@ -345,19 +428,45 @@ func (e *encodeState) marshalMap(v reflect.Value) (ast.Node, error) {
 	e.seen[ptr] = struct{}{}
 	defer delete(e.seen, ptr)
 
-	if v.Type().Key().Kind() != reflect.String {
-		return nil, fmt.Errorf("maml: map key type must be a string, got %s", v.Type().Key())
+	if !isEncodableKey(v.Type().Key()) {
+		return nil, fmt.Errorf("maml: unsupported map key type %s", v.Type().Key())
 	}
 
-	pairs := make([]*ast.KeyValueExpression, 0, v.Len())
-	keys := v.MapKeys()
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i].String() < keys[j].String()
+	type entry struct {
+		key   reflect.Value
+		name  string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKeyString(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: iter.Key(), name: name, value: iter.Value()})
+	}
+	// Keys written as bare integers are sorted numerically, all others by
+	// their MAML key, including integers encoded by a TextMarshaler.
+	bareInt := !v.Type().Key().Implements(textMarshalerType)
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].key, entries[j].key
+		switch {
+		case bareInt && a.CanInt():
+			return a.Int() < b.Int()
+		case bareInt && a.CanUint():
+			return a.Uint() < b.Uint()
+		default:
+			return entries[i].name < entries[j].name
+		}
 	})
-	for _, key := range keys {
-		value := v.MapIndex(key)
+	if e.opts.keyOrder == orderCustom {
+		slices.SortStableFunc(entries, func(a, b entry) int { return e.opts.compareKeys(a.name, b.name) })
+	}
 
-		valueNode, err := e.marshalValue(value)
+	pairs := make([]*ast.KeyValueExpression, 0, len(entries))
+	for _, entry := range entries {
+		valueNode, err := e.marshalValue(entry.value)
 		if err != nil {
 			return nil, err
 		}
@@ -366,66 +475,71 @@ func (e *encodeState) marshalMap(v reflect.Value) (ast.Node, error) {
 			return nil, fmt.Errorf("maml: marshaled map value is not an expression")
 		}
 
-		keyStr := key.String()
-
-		var keyNode ast.Expression
-		if isBareKey(keyStr) {
-			tok := token.Token{Literal: keyStr}
-			if typ, ok := lexer.ParseAsNumber(keyStr); ok {
-				tok.Type = typ
-			} else {
-				tok.Type = token.IDENT
-			}
-			keyNode = &ast.Identifier{Token: tok, Value: keyStr}
-		} else {
-			keyNode = &ast.StringLiteral{
-				Token: token.Token{Type: token.STRING, Literal: keyStr},
-				Value: keyStr,
-			}
-		}
-
-		pairs = append(pairs, &ast.KeyValueExpression{
-			Token: token.Token{Type: token.COLON, Literal: ":"},
-			Key:   keyNode,
-			Value: valueExpr,
-		})
+		pairs = append(pairs, ast.NewKeyValue(entry.name, valueExpr))
 	}
 
-	return &ast.ObjectLiteral{
-		Token: token.Token{Type: token.LBRACE, Literal: "{"},
-		Pairs: pairs,
-	}, nil
+	return ast.NewObject(pairs...), nil
 }
 
-func (e *encodeState) marshalStruct(v reflect.Value) (ast.Node, error) { //nolint:gocognit
-	pairs := make([]*ast.KeyValueExpression, 0, v.NumField())
-	t := v.Type()
-
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		fieldValue := v.Field(i)
+// isEncodableKey reports whether map keys of type t can be encoded as object
+// keys.
+func isEncodableKey(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return t.Implements(textMarshalerType)
+}
 
-		if !field.IsExported() {
-			continue
+// mapKeyString returns the object key for a map key. Keys of a string kind
+// are used as is; other keys are encoded with their encoding.TextMarshaler
+// implementation, or as integers or booleans. Numeric keys are written bare
+// by ast.NewKey.
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := m.MarshalText()
+		if err != nil {
+			return "", &MarshalerError{Type: k.Type(), Err: err}
 		}
+		return string(b), nil
+	}
+	switch {
+	case k.Kind() == reflect.Bool:
+		return strconv.FormatBool(k.Bool()), nil
+	case k.CanInt():
+		return strconv.FormatInt(k.Int(), 10), nil
+	default:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+}
 
-		tagStr := field.Tag.Get("maml")
-		tagName, opts := parseTag(tagStr)
+func (e *encodeState) marshalStruct(v reflect.Value) (ast.Node, error) {
+	fields := cachedEncodeFields(v.Type())
+	pairs := make([]*ast.KeyValueExpression, 0, len(fields))
+	commenter := structCommenter(v)
 
-		if tagName == "-" {
+	for _, f := range fields {
+		fieldValue, ok := fieldByIndex(v, f.idx)
+		if !ok {
 			continue
 		}
 
-		if opts["omitempty"] && isEmptyValue(fieldValue) {
+		if f.omitEmpty && isEmptyValue(fieldValue) {
 			continue
 		}
 
-		keyStr := field.Name
-		if tagName != "" {
-			keyStr = tagName
-		}
-
+		outerBytes := e.bytes
+		e.bytes = f.bytes
 		valueNode, err := e.marshalValue(fieldValue)
+		e.bytes = outerBytes
 		if err != nil {
 			return nil, err
 		}
@@ -434,31 +548,183 @@ func (e *encodeState) marshalStruct(v reflect.Value) (ast.Node, error) { //nolin
 			return nil, fmt.Errorf("maml: marshaled struct field value is not an expression")
 		}
 
-		var keyNode ast.Expression
-		if isBareKey(keyStr) {
-			tok := token.Token{Literal: keyStr}
-			if typ, ok := lexer.ParseAsNumber(keyStr); ok {
-				tok.Type = typ
-			} else {
-				tok.Type = token.IDENT
+		pair := ast.NewKeyValue(f.name, valueExpr)
+		head, line := f.headComment, f.lineComment
+		if commenter != nil {
+			if h, l := commenter.CommentMAML(f.name); h != "" || l != "" {
+				head, line = h, l
 			}
-			keyNode = &ast.Identifier{Token: tok, Value: keyStr}
-		} else {
-			keyNode = &ast.StringLiteral{
-				Token: token.Token{Type: token.STRING, Literal: keyStr},
-				Value: keyStr,
+		}
+		pair.HeadComments = headComments(head)
+		if line != "" {
+			pair.LineComment = ast.NewComment(strings.Join(strings.Fields(line), " "))
+		}
+		pairs = append(pairs, pair)
+	}
+	e.sortFields(pairs)
+
+	obj := ast.NewObject(pairs...)
+	if e.structTypes != nil {
+		e.structTypes[obj] = v.Type()
+	}
+	return obj, nil
+}
+
+// sortFields sorts the pairs of an encoded struct according to the key order
+// option. By default they are left in declaration order.
+func (e *encodeState) sortFields(pairs []*ast.KeyValueExpression) {
+	var cmp func(a, b string) int
+	switch e.opts.keyOrder {
+	case orderSorted:
+		cmp = strings.Compare
+	case orderCustom:
+		cmp = e.opts.compareKeys
+	default:
+		return
+	}
+	slices.SortStableFunc(pairs, func(a, b *ast.KeyValueExpression) int {
+		return cmp(a.KeyString(), b.KeyString())
+	})
+}
+
+var commenterType = reflect.TypeFor[Commenter]()
+
+// structCommenter returns the Commenter implementation of the struct v, or
+// of a pointer to it, if any.
+func structCommenter(v reflect.Value) Commenter {
+	if !v.CanInterface() {
+		return nil
+	}
+	if c, ok := v.Interface().(Commenter); ok {
+		return c
+	}
+	if !reflect.PointerTo(v.Type()).Implements(commenterType) {
+		return nil
+	}
+	c, _ := addressable(v).Interface().(Commenter)
+	return c
+}
+
+// headComments returns the comments for the lines of text. Trailing newlines
+// are ignored, so that the text of a comment tag can end with one.
+func headComments(text string) []*ast.Comment {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	comments := make([]*ast.Comment, len(lines))
+	for i, line := range lines {
+		comments[i] = ast.NewComment(strings.TrimRight(line, " \t\r"))
+	}
+	return comments
+}
+
+// fieldByIndex returns the field of the struct v with the given index
+// sequence. It reports false if the path goes through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, idx []int) (reflect.Value, bool) {
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
 			}
+			v = v.Elem()
 		}
+		v = v.Field(x)
+	}
+	return v, true
+}
 
-		pairs = append(pairs, &ast.KeyValueExpression{
-			Token: token.Token{Type: token.COLON, Literal: ":"},
-			Key:   keyNode,
-			Value: valueExpr,
+// An encodeField is a struct field written by the encoder.
+type encodeField struct {
+	name        string
+	idx         []int
+	omitEmpty   bool
+	bytes       byteEncoding
+	headComment string // from the comment tag
+	lineComment string // from the linecomment tag
+}
+
+// encodeFieldCache caches the encoded fields of struct types.
+var encodeFieldCache sync.Map // map[reflect.Type][]encodeField
+
+// cachedEncodeFields returns the fields of the struct type t in the order
+// they are written, with the fields of embedded structs flattened into it.
+//
+// When several fields share a name, the rules of encoding/json apply: the
+// shallowest fields win, and among those a single tagged field wins.
+// Otherwise the name is ambiguous and all of its fields are dropped.
+func cachedEncodeFields(t reflect.Type) []encodeField {
+	if f, ok := encodeFieldCache.Load(t); ok {
+		if fields, ok := f.([]encodeField); ok {
+			return fields
+		}
+	}
+
+	type fieldEntry struct {
+		f      encodeField
+		depth  int
+		tagged bool
+	}
+	var entries []fieldEntry
+	visitFields(t, func(sf reflect.StructField, idx []int, depth int) {
+		tagName, opts := parseTag(sf.Tag.Get("maml"))
+		name := sf.Name
+		if tagName != "" {
+			name = tagName
+		}
+		entries = append(entries, fieldEntry{
+			f: encodeField{
+				name:        name,
+				idx:         idx,
+				omitEmpty:   opts["omitempty"],
+				bytes:       tagByteEncoding(opts),
+				headComment: sf.Tag.Get("comment"),
+				lineComment: sf.Tag.Get("linecomment"),
+			},
+			depth:  depth,
+			tagged: tagName != "",
 		})
+	})
+
+	byName := make(map[string][]int) // indexes into entries
+	for i, entry := range entries {
+		byName[entry.f.name] = append(byName[entry.f.name], i)
+	}
+
+	// Find the dominant field for each name, or -1 if the name is ambiguous.
+	dominant := make(map[string]int, len(byName))
+	for name, idxs := range byName {
+		minDepth := entries[idxs[0]].depth
+		for _, i := range idxs[1:] {
+			minDepth = min(minDepth, entries[i].depth)
+		}
+		var shallow, tagged []int
+		for _, i := range idxs {
+			if entries[i].depth == minDepth {
+				shallow = append(shallow, i)
+				if entries[i].tagged {
+					tagged = append(tagged, i)
+				}
+			}
+		}
+		switch {
+		case len(shallow) == 1:
+			dominant[name] = shallow[0]
+		case len(tagged) == 1:
+			dominant[name] = tagged[0]
+		default:
+			dominant[name] = -1
+		}
+	}
+
+	fields := make([]encodeField, 0, len(entries))
+	for i, entry := range entries {
+		if dominant[entry.f.name] == i {
+			fields = append(fields, entry.f)
+		}
 	}
 
-	return &ast.ObjectLiteral{
-		Token: token.Token{Type: token.LBRACE, Literal: "{"},
-		Pairs: pairs,
-	}, nil
+	encodeFieldCache.Store(t, fields)
+	return fields
 }
@@ -0,0 +1,244 @@
+package maml
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal_EmbeddedStructs(t *testing.T) {
+	type Tagged struct {
+		CommonField string `maml:"Common"`
+	}
+	type Untagged struct {
+		Common string
+	}
+	type Optional struct {
+		City string `maml:",omitempty"`
+		Zip  string
+	}
+	type Recursive struct {
+		Name string
+		*Recursive
+	}
+	type Secret struct {
+		Password string
+	}
+
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{
+			name: "Embedded struct (value)",
+			input: struct {
+				Name string
+				Address
+			}{Name: "John Doe", Address: Address{City: "New York", PostalCode: "10001"}},
+			expected: `{Name:"John Doe",City:"New York",PostalCode:"10001"}`,
+		},
+		{
+			name: "Embedded struct (pointer)",
+			input: struct {
+				Name string
+				*Address
+			}{Name: "Jane Doe", Address: &Address{City: "London", PostalCode: "SW1A 0AA"}},
+			expected: `{Name:"Jane Doe",City:"London",PostalCode:"SW1A 0AA"}`,
+		},
+		{
+			name: "Nil embedded pointer is skipped",
+			input: struct {
+				Name string
+				*Address
+			}{Name: "Jane Doe"},
+			expected: `{Name:"Jane Doe"}`,
+		},
+		{
+			name: "Fields are written in declaration order",
+			input: struct {
+				Address
+				Name string
+			}{Name: "John Doe", Address: Address{City: "Oslo"}},
+			expected: `{City:"Oslo",PostalCode:"",Name:"John Doe"}`,
+		},
+		{
+			name: "Tags of embedded fields",
+			input: struct {
+				TaggedAddress
+			}{TaggedAddress{City: "Paris", PostalCode: "75001"}},
+			expected: `{homeCity:"Paris",postalCode:"75001"}`,
+		},
+		{
+			name: "Shallower field shadows embedded field",
+			input: struct {
+				Name string
+				UserWithID
+			}{Name: "outer", UserWithID: UserWithID{ID: 1, Name: "inner"}},
+			expected: `{Name:"outer",ID:1}`,
+		},
+		{
+			name: "Multiple levels of embedding",
+			input: struct {
+				DetailedAddress
+			}{DetailedAddress{Address: Address{City: "Rome"}, Country: Country{Name: "Italy"}}},
+			expected: `{City:"Rome",PostalCode:"",countryName:"Italy"}`,
+		},
+		{
+			name: "Conflicting names at the same depth are dropped",
+			input: struct {
+				Name string
+				Embedded1
+				Embedded2
+			}{Name: "x", Embedded1: Embedded1{CommonField: "a"}, Embedded2: Embedded2{CommonField: "b"}},
+			expected: `{Name:"x"}`,
+		},
+		{
+			name: "Tagged field wins a conflict at the same depth",
+			input: struct {
+				Untagged
+				Tagged
+			}{Untagged{Common: "untagged"}, Tagged{CommonField: "tagged"}},
+			expected: `{Common:"tagged"}`,
+		},
+		{
+			name: "Omitempty on embedded fields",
+			input: struct {
+				Name string
+				*Optional
+			}{Name: "n", Optional: &Optional{Zip: "z"}},
+			expected: `{Name:"n",Zip:"z"}`,
+		},
+		{
+			name: "Embedded struct tagged with -",
+			input: struct {
+				Name   string
+				Secret `maml:"-"`
+			}{Name: "n", Secret: Secret{Password: "p"}},
+			expected: `{Name:"n"}`,
+		},
+		{
+			name: "Embedded struct with a tag name is nested",
+			input: struct {
+				Name     string
+				Address  `maml:"address"`
+				*Country `maml:"country,omitempty"`
+			}{Name: "n", Address: Address{City: "Oslo"}},
+			expected: `{Name:"n",address:{City:"Oslo",PostalCode:""}}`,
+		},
+		{
+			name:     "Recursive embedding",
+			input:    Recursive{Name: "outer", Recursive: &Recursive{Name: "inner"}},
+			expected: `{Name:"outer"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Marshal(tt.input, Indent(0))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(b))
+		})
+	}
+}
+
+func TestMarshal_EmbeddedStructsRoundTrip(t *testing.T) {
+	type User struct {
+		Name string
+		*TaggedAddress
+		UserWithID
+	}
+	in := User{
+		Name:          "Jane Doe",
+		TaggedAddress: &TaggedAddress{City: "London", PostalCode: "SW1A 0AA"},
+		UserWithID:    UserWithID{ID: 7},
+	}
+
+	b, err := Marshal(in)
+	require.NoError(t, err)
+
+	var out User
+	require.NoError(t, Unmarshal(b, &out))
+	require.Equal(t, in, out)
+}
+
+func TestEmbeddedStructsRoundTrip(t *testing.T) {
+	type Secret struct {
+		Password string
+	}
+	type A struct {
+		Name string
+		ID   int
+	}
+	type B struct {
+		Name string
+	}
+	type Excluded struct {
+		Name   string
+		Secret `maml:"-"`
+	}
+	type Nested struct {
+		Name     string
+		Address  `maml:"address"`
+		*Country `maml:"country"`
+	}
+	type Conflict struct {
+		A
+		B
+	}
+
+	tests := []struct {
+		name    string
+		in      any
+		encoded string
+		out     any // decoded from encoded, into a new value of the type of in
+	}{
+		{
+			name:    "Tagged with -",
+			in:      Excluded{Name: "n", Secret: Secret{Password: "p"}},
+			encoded: `{Name:"n"}`,
+			out:     Excluded{Name: "n"},
+		},
+		{
+			name:    "Tag name",
+			in:      Nested{Name: "n", Address: Address{City: "Oslo", PostalCode: "0150"}, Country: &Country{Name: "Norway"}},
+			encoded: `{Name:"n",address:{City:"Oslo",PostalCode:"0150"},country:{countryName:"Norway"}}`,
+			out:     Nested{Name: "n", Address: Address{City: "Oslo", PostalCode: "0150"}, Country: &Country{Name: "Norway"}},
+		},
+		{
+			name:    "Conflicting names are omitted",
+			in:      Conflict{A: A{Name: "a", ID: 1}, B: B{Name: "b"}},
+			encoded: `{ID:1}`,
+			out:     Conflict{A: A{ID: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Marshal(tt.in, Indent(0))
+			require.NoError(t, err)
+			require.Equal(t, tt.encoded, string(b))
+
+			out := reflect.New(reflect.TypeOf(tt.in))
+			require.NoError(t, Unmarshal(b, out.Interface()))
+			require.Equal(t, tt.out, out.Elem().Interface())
+		})
+	}
+
+	t.Run("Decoding", func(t *testing.T) {
+		var excluded Excluded
+		require.NoError(t, Unmarshal([]byte(`{Name: "n", Password: "p"}`), &excluded))
+		require.Equal(t, Excluded{Name: "n"}, excluded)
+
+		// The decoder keeps its rule of giving a name to the first declared
+		// field at the shallowest depth.
+		var conflict Conflict
+		require.NoError(t, Unmarshal([]byte(`{Name: "x", ID: 2}`), &conflict))
+		require.Equal(t, Conflict{A: A{Name: "x", ID: 2}}, conflict)
+
+		var nested Nested
+		require.NoError(t, Unmarshal([]byte(`{City: "Oslo", address: {City: "Bergen"}}`), &nested))
+		require.Equal(t, Nested{Address: Address{City: "Bergen"}}, nested)
+	})
+}
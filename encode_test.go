@@ -3,10 +3,13 @@ package maml_test
 import (
 	"bytes"
 	"encoding/json"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/KimNorgaard/go-maml"
 	"github.com/KimNorgaard/go-maml/internal/testutil"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -49,3 +52,132 @@ func BenchmarkEncode(b *testing.B) {
 		buf.Reset()
 	}
 }
+
+func TestMarshal_Comments(t *testing.T) {
+	type Limits struct {
+		CPU    string `maml:"cpu" comment:"CPU limit in cores." linecomment:"e.g. 500m"`
+		Memory string `maml:"memory"`
+	}
+	type Config struct {
+		Name   string `maml:"name" comment:"Name of the service.\nMust be unique."`
+		Port   int    `maml:"port" linecomment:"the listen port"`
+		Limits Limits `maml:"limits" comment:"Resource limits.\n\nApplied per replica.\n"`
+	}
+	in := Config{Name: "api", Port: 8080, Limits: Limits{CPU: "1", Memory: "1Gi"}}
+
+	b, err := maml.Marshal(in)
+	require.NoError(t, err)
+	expected := `{
+  # Name of the service.
+  # Must be unique.
+  name: "api"
+  port: 8080 # the listen port
+  # Resource limits.
+  #
+  # Applied per replica.
+  limits: {
+    # CPU limit in cores.
+    cpu: "1" # e.g. 500m
+    memory: "1Gi"
+  }
+}`
+	require.Equal(t, expected, string(b))
+
+	var out Config
+	require.NoError(t, maml.Unmarshal(b, &out))
+	require.Equal(t, in, out)
+
+	b, err = maml.Marshal(in, maml.Indent(0))
+	require.NoError(t, err)
+	require.Equal(t, `{name:"api",port:8080,limits:{cpu:"1",memory:"1Gi"}}`, string(b), "compact output has no comments")
+}
+
+type commentedServer struct {
+	Host string `comment:"from the tag"`
+	Port int
+}
+
+func (s commentedServer) CommentMAML(key string) (head, line string) {
+	switch key {
+	case "Host":
+		return "The host to bind to.", ""
+	case "Port":
+		return "", "default " + strconv.Itoa(s.Port)
+	}
+	return "", ""
+}
+
+type pointerCommenter struct {
+	Debug bool
+}
+
+func (*pointerCommenter) CommentMAML(key string) (head, line string) {
+	return "Enables " + key + ".", ""
+}
+
+func TestMarshal_Commenter(t *testing.T) {
+	b, err := maml.Marshal(commentedServer{Host: "0.0.0.0", Port: 80})
+	require.NoError(t, err)
+	require.Equal(t, "{\n  # The host to bind to.\n  Host: \"0.0.0.0\"\n  Port: 80 # default 80\n}", string(b))
+
+	b, err = maml.Marshal([]pointerCommenter{{Debug: true}})
+	require.NoError(t, err)
+	require.Equal(t, "[\n  {\n    # Enables Debug.\n    Debug: true\n  }\n]", string(b))
+}
+
+func TestMarshal_KeyOrder(t *testing.T) {
+	type service struct {
+		Name    string         `maml:"name"`
+		Version int            `maml:"version"`
+		Enabled bool           `maml:"enabled"`
+		Limits  map[string]int `maml:"limits"`
+	}
+	in := service{Name: "api", Version: 2, Enabled: true, Limits: map[string]int{"memory": 512, "cpu": 2, "disk": 10}}
+
+	tests := []struct {
+		name string
+		opts []maml.Option
+		want string
+	}{
+		{
+			name: "Default",
+			want: `{name:"api",version:2,enabled:true,limits:{cpu:2,disk:10,memory:512}}`,
+		},
+		{
+			name: "Declaration order",
+			opts: []maml.Option{maml.SortKeys(), maml.DeclarationOrder()},
+			want: `{name:"api",version:2,enabled:true,limits:{cpu:2,disk:10,memory:512}}`,
+		},
+		{
+			name: "Sorted",
+			opts: []maml.Option{maml.SortKeys()},
+			want: `{enabled:true,limits:{cpu:2,disk:10,memory:512},name:"api",version:2}`,
+		},
+		{
+			name: "Comparator",
+			opts: []maml.Option{maml.SortKeysFunc(func(a, b string) int { return len(a) - len(b) })},
+			want: `{name:"api",limits:{cpu:2,disk:10,memory:512},version:2,enabled:true}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := maml.Marshal(in, append(tt.opts, maml.Indent(0))...)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(b))
+		})
+	}
+
+	t.Run("Integer map keys", func(t *testing.T) {
+		m := map[int]string{10: "a", 9: "b", 100: "c"}
+		b, err := maml.Marshal(m, maml.Indent(0), maml.SortKeys())
+		require.NoError(t, err)
+		require.Equal(t, `{9:"b",10:"a",100:"c"}`, string(b))
+
+		b, err = maml.Marshal(m, maml.Indent(0), maml.SortKeysFunc(strings.Compare))
+		require.NoError(t, err)
+		require.Equal(t, `{10:"a",100:"c",9:"b"}`, string(b))
+	})
+
+	_, err := maml.Marshal(in, maml.SortKeysFunc(nil))
+	require.EqualError(t, err, "maml: key comparator must not be nil")
+}
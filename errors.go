@@ -1,9 +1,233 @@
 package maml
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml/token"
 )
 
+// The decode errors below carry the key path and source range of the value
+// they describe. Besides Error, they implement errors.Diagnostic so that
+// errors.Render can show them under a source snippet: Span returns the range
+// and Detail returns the message and key path without the position, which
+// the renderer prints itself.
+
+// An UnmarshalTypeError describes a MAML value that was not appropriate for
+// the Go value it was decoded into.
+type UnmarshalTypeError struct {
+	Value string         // MAML kind of the value: "string", "integer", "object", ...
+	Type  reflect.Type   // type of the Go value it could not be assigned to
+	Path  string         // key path to the value, e.g. "spec.replicas"; empty for the root
+	Pos   token.Position // position of the value in the source
+	End   token.Position // position immediately after the value
+}
+
+func (e *UnmarshalTypeError) message() string {
+	return "cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *UnmarshalTypeError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *UnmarshalTypeError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+// An InvalidValueError describes a MAML value of the right kind whose content
+// could not be parsed into the Go value it was decoded into, such as a
+// string that is not a valid time.Duration or an object key that is not a
+// valid integer map key.
+type InvalidValueError struct {
+	Value string         // the value as described in the error message: "string" or `key "x"`
+	Type  reflect.Type   // type of the Go value it could not be parsed into
+	Path  string         // key path to the value; empty for the root
+	Pos   token.Position // position of the value in the source
+	End   token.Position // position immediately after the value
+	Err   error          // the error returned by the parser of the Go type
+}
+
+func (e *InvalidValueError) message() string {
+	return "cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *InvalidValueError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *InvalidValueError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *InvalidValueError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+func (e *InvalidValueError) Unwrap() error { return e.Err }
+
+// An OverflowError describes a MAML number that does not fit in the Go value
+// it was decoded into, including negative numbers decoded into unsigned
+// integers.
+type OverflowError struct {
+	Value string         // the number as it appears in the error message
+	Kind  string         // MAML kind of the value: "integer" or "float"
+	Type  reflect.Type   // type of the Go value it could not be assigned to
+	Path  string         // key path to the value; empty for the root
+	Pos   token.Position // position of the value in the source
+	End   token.Position // position immediately after the value
+}
+
+func (e *OverflowError) message() string {
+	return e.Kind + " value " + e.Value + " overflows Go value of type " + e.Type.String()
+}
+
+func (e *OverflowError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *OverflowError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *OverflowError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+// An ArrayLengthError describes a MAML array whose number of elements differs
+// from the length of the Go array it was decoded into.
+type ArrayLengthError struct {
+	Len  int            // number of elements in the MAML array
+	Type reflect.Type   // type of the Go array
+	Path string         // key path to the array; empty for the root
+	Pos  token.Position // position of the array in the source
+	End  token.Position // position immediately after the array
+}
+
+func (e *ArrayLengthError) message() string {
+	return fmt.Sprintf("cannot unmarshal array of length %d into Go array of length %d", e.Len, e.Type.Len())
+}
+
+func (e *ArrayLengthError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *ArrayLengthError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *ArrayLengthError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+// An UnknownFieldError describes an object key that does not match any field
+// of the destination struct. It is only returned when the DisallowUnknownFields
+// option is used.
+type UnknownFieldError struct {
+	Field string         // the key as written in the document
+	Type  reflect.Type   // the struct type that has no matching field
+	Path  string         // key path to the field, including the field itself
+	Pos   token.Position // position of the key in the source
+	End   token.Position // position immediately after the key
+}
+
+func (e *UnknownFieldError) message() string {
+	return fmt.Sprintf("unknown field %q in type %s", e.Field, e.Type)
+}
+
+func (e *UnknownFieldError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *UnknownFieldError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *UnknownFieldError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+// A MissingFieldError describes a struct field tagged `maml:",required"`
+// that is missing from the object it is decoded from.
+type MissingFieldError struct {
+	Field string         // the name of the missing field
+	Type  reflect.Type   // the struct type the field belongs to
+	Path  string         // key path to the object; empty for the root
+	Pos   token.Position // position of the object in the source
+	End   token.Position // position immediately after the object
+}
+
+func (e *MissingFieldError) message() string {
+	return fmt.Sprintf("missing required field %q in type %s", e.Field, e.Type)
+}
+
+func (e *MissingFieldError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *MissingFieldError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *MissingFieldError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+// A ValidationError describes a struct value that was rejected by its
+// ValidateMAML method.
+type ValidationError struct {
+	Type reflect.Type   // the struct type that failed validation
+	Path string         // key path to the object; empty for the root
+	Pos  token.Position // position of the object in the source
+	End  token.Position // position immediately after the object
+	Err  error          // the error returned by ValidateMAML
+}
+
+func (e *ValidationError) message() string {
+	return "invalid " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Error() string {
+	return "maml: " + e.message() + location(e.Path, e.Pos)
+}
+
+func (e *ValidationError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *ValidationError) Detail() string                    { return e.message() + location(e.Path, token.Position{}) }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// An InterpolationError describes a string value whose variable references
+// could not be expanded. It is only returned when the ExpandVariables option
+// is used.
+type InterpolationError struct {
+	Variable string         // the variable that is not set, if any
+	Msg      string         // description of the error
+	Path     string         // key path to the string; empty for the root
+	Pos      token.Position // position of the string in the source
+	End      token.Position // position immediately after the string
+}
+
+func (e *InterpolationError) Error() string {
+	return "maml: " + e.Msg + location(e.Path, e.Pos)
+}
+
+func (e *InterpolationError) Span() (start, end token.Position) { return e.Pos, e.End }
+func (e *InterpolationError) Detail() string                    { return e.Msg + location(e.Path, token.Position{}) }
+
+// DecodeErrors is returned by the decoder when the AllErrors option is used
+// and one or more values could not be decoded. It lists the errors in the
+// order they were found. Use errors.As to retrieve the individual
+// UnmarshalTypeError, InvalidValueError, OverflowError, ArrayLengthError,
+// UnknownFieldError, MissingFieldError, ValidationError, InterpolationError
+// and UnmarshalerError values.
+type DecodeErrors []error
+
+func (e DecodeErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "maml: %d decode errors:", len(e))
+	for _, err := range e {
+		b.WriteString("\n\t")
+		b.WriteString(strings.TrimPrefix(err.Error(), "maml: "))
+	}
+	return b.String()
+}
+
+// Unwrap returns the individual errors.
+func (e DecodeErrors) Unwrap() []error { return e }
+
+// location describes where in the document a decode error occurred, for
+// appending to an error message.
+func location(path string, pos token.Position) string {
+	switch {
+	case path != "" && pos.IsValid():
+		return fmt.Sprintf(" (%s at line %d, column %d)", path, pos.Line, pos.Column)
+	case path != "":
+		return " (" + path + ")"
+	case pos.IsValid():
+		return fmt.Sprintf(" (at line %d, column %d)", pos.Line, pos.Column)
+	default:
+		return ""
+	}
+}
+
 // A MarshalerError represents an error from calling a MarshalMAML method.
 type MarshalerError struct {
 	Type reflect.Type
@@ -1,6 +1,12 @@
+// Package errors defines the errors reported while parsing MAML documents and
+// a renderer that displays them alongside the offending source.
 package errors
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/KimNorgaard/go-maml/token"
+)
 
 // ParseError represents a single error that occurred during parsing.
 // It includes the position of the error.
@@ -8,8 +14,22 @@ type ParseError struct {
 	Message string
 	Line    int
 	Column  int
+	Offset  int            // byte offset of the offending token
+	End     token.Position // position immediately after the offending token
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("maml: parsing error at line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Span returns the start and end position of the offending token.
+func (e ParseError) Span() (start, end token.Position) {
+	return token.Position{Offset: e.Offset, Line: e.Line, Column: e.Column}, e.End
 }
 
+// Detail returns the error message without position information.
+func (e ParseError) Detail() string { return e.Message }
+
 // ParseErrors is a slice of ParseError that implements the error interface.
 // This allows returning all syntax errors found during parsing at once.
 type ParseErrors []ParseError
@@ -20,5 +40,14 @@ func (p ParseErrors) Error() string {
 	}
 	// For simplicity, the default error message for the collection
 	// just reports the first error.
-	return fmt.Sprintf("maml: parsing error at line %d, column %d: %s", p[0].Line, p[0].Column, p[0].Message)
+	return p[0].Error()
+}
+
+// Unwrap returns the individual parse errors.
+func (p ParseErrors) Unwrap() []error {
+	errs := make([]error, len(p))
+	for i, e := range p {
+		errs[i] = e
+	}
+	return errs
 }
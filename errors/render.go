@@ -0,0 +1,162 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+// A Diagnostic is an error that refers to a span of the source document.
+// ParseError and the positioned decode errors of the maml package implement
+// it.
+type Diagnostic interface {
+	error
+	// Span returns the start position and the position immediately after
+	// the end of the source the error refers to.
+	Span() (start, end token.Position)
+	// Detail returns the error message without position information.
+	Detail() string
+}
+
+// ANSI escape sequences used when colour output is enabled.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[1;31m"
+	ansiBlue  = "\x1b[1;34m"
+)
+
+// Renderer formats errors for humans in the style of gcc and rustc: a
+// "file:line:column: error: message" header followed by the offending source
+// line and a ^~~~ marker under the span the error refers to.
+type Renderer struct {
+	// Filename is printed in front of each error. It is omitted if empty.
+	Filename string
+	// Color enables ANSI colour escape sequences.
+	Color bool
+}
+
+// Render writes every error contained in err to w, using src to show the
+// offending source lines. Errors that wrap multiple errors, such as
+// ParseErrors, are expanded into their individual errors. Errors that are
+// not Diagnostics are written without a source snippet.
+func (r *Renderer) Render(w io.Writer, src []byte, err error) error {
+	var buf bytes.Buffer
+	lines := bytes.Split(src, []byte("\n"))
+	for _, e := range flatten(err) {
+		if d, ok := e.(Diagnostic); ok {
+			r.renderDiagnostic(&buf, lines, d)
+		} else {
+			r.renderHeader(&buf, "", strings.TrimPrefix(e.Error(), "maml: "))
+		}
+	}
+	_, werr := w.Write(buf.Bytes())
+	return werr
+}
+
+// flatten returns the errors contained in err, expanding errors that wrap
+// multiple errors.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Diagnostic); ok {
+		return []error{err}
+	}
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range u.Unwrap() {
+			errs = append(errs, flatten(e)...)
+		}
+		return errs
+	}
+	return []error{err}
+}
+
+func (r *Renderer) renderDiagnostic(buf *bytes.Buffer, lines [][]byte, d Diagnostic) {
+	start, end := d.Span()
+	if !start.IsValid() {
+		r.renderHeader(buf, "", d.Detail())
+		return
+	}
+	r.renderHeader(buf, start.String(), d.Detail())
+	if start.Line > len(lines) {
+		return
+	}
+	line := strings.TrimSuffix(string(lines[start.Line-1]), "\r")
+
+	number := strconv.Itoa(start.Line)
+	gutter := strings.Repeat(" ", len(number)+2) + "|"
+	r.paint(buf, ansiBlue, " "+number+" |")
+	buf.WriteString(" " + line + "\n")
+	r.paint(buf, ansiBlue, gutter)
+	buf.WriteString(" ")
+
+	// Pad up to the start column, keeping tabs so that the marker lines up
+	// with the source line.
+	col := 1
+	for _, ch := range line {
+		if col >= start.Column {
+			break
+		}
+		if ch == '\t' {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+		col++
+	}
+	if col < start.Column {
+		buf.WriteString(strings.Repeat(" ", start.Column-col))
+	}
+
+	r.paint(buf, ansiRed, "^"+strings.Repeat("~", spanWidth(line, start, end)-1))
+	buf.WriteString("\n")
+}
+
+// spanWidth returns the number of columns to underline on the line of start.
+// Spans that continue on later lines are underlined to the end of the line.
+func spanWidth(line string, start, end token.Position) int {
+	width := 1
+	switch {
+	case end.Line == start.Line:
+		width = end.Column - start.Column
+	case end.Line > start.Line:
+		width = utf8.RuneCountInString(line) - start.Column + 1
+	}
+	return max(width, 1)
+}
+
+// renderHeader writes the "file:line:column: error: message" line. pos is
+// the "line:column" string, or empty if the error has no position.
+func (r *Renderer) renderHeader(buf *bytes.Buffer, pos, msg string) {
+	var loc []string
+	if r.Filename != "" {
+		loc = append(loc, r.Filename)
+	}
+	if pos != "" {
+		loc = append(loc, pos)
+	}
+	if len(loc) > 0 {
+		r.paint(buf, ansiBold, strings.Join(loc, ":")+":")
+		buf.WriteString(" ")
+	}
+	r.paint(buf, ansiRed, "error:")
+	buf.WriteString(" ")
+	r.paint(buf, ansiBold, msg)
+	buf.WriteString("\n")
+}
+
+// paint writes s, wrapped in the given escape sequence if colour is enabled.
+func (r *Renderer) paint(buf *bytes.Buffer, code, s string) {
+	if r.Color {
+		fmt.Fprintf(buf, "%s%s%s", code, s, ansiReset)
+		return
+	}
+	buf.WriteString(s)
+}
@@ -0,0 +1,94 @@
+package errors_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	mamlerrors "github.com/KimNorgaard/go-maml/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func render(t *testing.T, r *mamlerrors.Renderer, src string, err error) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, r.Render(&buf, []byte(src), err))
+	return buf.String()
+}
+
+func TestParseErrors_Unwrap(t *testing.T) {
+	_, err := maml.Parse([]byte("{\n  a: 1\n  a: 2\n  b: 3\n  b: 4\n}"))
+
+	var perrs mamlerrors.ParseErrors
+	require.ErrorAs(t, err, &perrs)
+	require.Len(t, perrs.Unwrap(), 2)
+
+	var perr mamlerrors.ParseError
+	require.ErrorAs(t, err, &perr)
+	require.Equal(t, 3, perr.Line)
+	require.Equal(t, "maml: parsing error at line 3, column 3: duplicate key in object: a", perr.Error())
+	require.Equal(t, err.Error(), perr.Error())
+}
+
+func TestRenderer_ParseErrors(t *testing.T) {
+	src := "{\n\tname: \"demo\"\n\tname: \"again\"\n\tport: 8080x\n}"
+	_, err := maml.Parse([]byte(src))
+	require.Error(t, err)
+
+	expected := "config.maml:3:2: error: duplicate key in object: name\n" +
+		" 3 | \tname: \"again\"\n" +
+		"   | \t^~~~\n" +
+		"config.maml:4:8: error: invalid number format: 8080x\n" +
+		" 4 | \tport: 8080x\n" +
+		"   | \t      ^~~~~\n"
+	require.Equal(t, expected, render(t, &mamlerrors.Renderer{Filename: "config.maml"}, src, err))
+}
+
+func TestRenderer_DecodeErrors(t *testing.T) {
+	src := "{\n  name: 1\n  tags: [\"ü\", 2]\n}"
+	var v struct {
+		Name string
+		Tags []string
+	}
+	err := maml.Unmarshal([]byte(src), &v, maml.AllErrors())
+	require.Error(t, err)
+
+	expected := "2:9: error: cannot unmarshal integer into Go value of type string (name)\n" +
+		" 2 |   name: 1\n" +
+		"   |         ^\n" +
+		"3:15: error: cannot unmarshal integer into Go value of type string (tags[1])\n" +
+		" 3 |   tags: [\"ü\", 2]\n" +
+		"   |               ^\n"
+	require.Equal(t, expected, render(t, &mamlerrors.Renderer{}, src, err))
+}
+
+func TestRenderer_MultilineSpan(t *testing.T) {
+	src := "{\n  a: {\n    b: 1\n  }\n}"
+	var v struct{ A int }
+	err := maml.Unmarshal([]byte(src), &v)
+	require.Error(t, err)
+
+	expected := "2:6: error: cannot unmarshal object into Go value of type int (a)\n" +
+		" 2 |   a: {\n" +
+		"   |      ^\n"
+	require.Equal(t, expected, render(t, &mamlerrors.Renderer{}, src, err))
+}
+
+func TestRenderer_Color(t *testing.T) {
+	src := "[1, 2"
+	_, err := maml.Parse([]byte(src))
+	require.Error(t, err)
+
+	out := render(t, &mamlerrors.Renderer{Color: true}, src, err)
+	require.Contains(t, out, "\x1b[1;31merror:\x1b[0m")
+	require.Contains(t, out, "\x1b[1;34m 1 |\x1b[0m [1, 2\n")
+}
+
+func TestRenderer_PlainErrors(t *testing.T) {
+	r := &mamlerrors.Renderer{Filename: "x.maml"}
+	require.Equal(t, "x.maml: error: something went wrong\n", render(t, r, "", fmt.Errorf("maml: something went wrong")))
+	require.Equal(t, "x.maml: error: a\nx.maml: error: b\n", render(t, r, "", errors.Join(errors.New("a"), errors.New("b"))))
+	require.Empty(t, render(t, r, "", nil))
+}
@@ -5,7 +5,8 @@ import (
 	"io"
 	"strings"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/KimNorgaard/go-maml/internal/pretty"
 )
 
 // formatter writes a MAML AST to an output stream.
@@ -36,6 +37,13 @@ func newFormatter(w io.Writer, opts *options) *formatter {
 
 // format writes the MAML string representation of the AST node to the writer.
 func (f *formatter) format(node ast.Node) error {
+	if f.opts.maxLineWidth > 0 && f.indent != "" {
+		doc, err := f.rootDoc(node)
+		if err != nil {
+			return err
+		}
+		return f.write(pretty.Render(doc, f.opts.maxLineWidth, f.indent))
+	}
 	return f.writeNode(node)
 }
 
@@ -60,7 +68,7 @@ func (f *formatter) writeNode(node ast.Node) error { //nolint:gocognit
 	switch n := node.(type) {
 	case *ast.Document:
 		for _, comment := range n.HeadComments {
-			if err := f.write("# " + comment.Value + "\n"); err != nil {
+			if err := f.write(commentLine(comment) + "\n"); err != nil {
 				return err
 			}
 		}
@@ -112,16 +120,16 @@ func (f *formatter) writeMultilineString(s string) error {
 func (f *formatter) writePrettyObject(obj *ast.ObjectLiteral) error {
 	f.depth++
 	for i, pair := range obj.Pairs {
-		if err := f.writePairPrefix(i, pair); err != nil {
+		if err := f.writeItemPrefix(i, pair.NewlinesBefore, pair.HeadComments); err != nil {
 			return err
 		}
 		if err := f.writePairKeyValue(pair); err != nil {
 			return err
 		}
-		if err := f.writePairSuffix(i, len(obj.Pairs), pair); err != nil {
+		if err := f.writeItemSuffix(i, len(obj.Pairs), pair.LineComment); err != nil {
 			return err
 		}
-		if err := f.writePairFootComments(pair); err != nil {
+		if err := f.writeFootComments(pair.FootComments); err != nil {
 			return err
 		}
 	}
@@ -135,15 +143,16 @@ func (f *formatter) writePrettyObject(obj *ast.ObjectLiteral) error {
 	return f.writeIndent()
 }
 
-// writePairPrefix handles writing newlines, head comments, and indentation before the key.
-func (f *formatter) writePairPrefix(i int, pair *ast.KeyValueExpression) error {
+// writeItemPrefix handles writing newlines, head comments, and indentation
+// before an object pair or array element.
+func (f *formatter) writeItemPrefix(i, newlinesBefore int, headComments []*ast.Comment) error {
 	// Use the recorded number of newlines from the source to preserve vertical spacing.
-	numNewlines := pair.NewlinesBefore
+	numNewlines := newlinesBefore
 	if i == 0 {
-		// First pair is always one newline after '{'.
+		// First item is always one newline after the opening bracket.
 		numNewlines = 1
 	} else if numNewlines == 0 {
-		// Subsequent pairs need at least one newline for pretty printing.
+		// Subsequent items need at least one newline for pretty printing.
 		numNewlines = 1
 	}
 
@@ -153,11 +162,11 @@ func (f *formatter) writePairPrefix(i int, pair *ast.KeyValueExpression) error {
 		}
 	}
 
-	for _, comment := range pair.HeadComments {
+	for _, comment := range headComments {
 		if err := f.writeIndent(); err != nil {
 			return err
 		}
-		if err := f.write("# " + comment.Value + "\n"); err != nil {
+		if err := f.write(commentLine(comment) + "\n"); err != nil {
 			return err
 		}
 	}
@@ -165,6 +174,15 @@ func (f *formatter) writePairPrefix(i int, pair *ast.KeyValueExpression) error {
 	return f.writeIndent()
 }
 
+// commentLine returns the source text of a comment. Empty comments are
+// written without a trailing space.
+func commentLine(c *ast.Comment) string {
+	if c.Value == "" {
+		return "#"
+	}
+	return "# " + c.Value
+}
+
 // writePairKeyValue handles writing the "key: value" part of a pair.
 func (f *formatter) writePairKeyValue(pair *ast.KeyValueExpression) error {
 	if err := f.write(pair.Key.String() + ": "); err != nil {
@@ -173,10 +191,11 @@ func (f *formatter) writePairKeyValue(pair *ast.KeyValueExpression) error {
 	return f.writeNode(pair.Value)
 }
 
-// writePairSuffix handles writing commas and line comments after the value.
-func (f *formatter) writePairSuffix(i, pairCount int, pair *ast.KeyValueExpression) error {
+// writeItemSuffix handles writing commas and line comments after the value
+// of an object pair or array element.
+func (f *formatter) writeItemSuffix(i, count int, lineComment *ast.Comment) error {
 	if f.opts.useFieldCommas {
-		isLast := i == pairCount-1
+		isLast := i == count-1
 		if !isLast {
 			if err := f.write(","); err != nil {
 				return err
@@ -188,24 +207,25 @@ func (f *formatter) writePairSuffix(i, pairCount int, pair *ast.KeyValueExpressi
 		}
 	}
 
-	if pair.LineComment != nil {
-		if err := f.write(" # " + pair.LineComment.Value); err != nil {
+	if lineComment != nil {
+		if err := f.write(" " + commentLine(lineComment)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// writePairFootComments handles writing foot comments after a key-value pair.
-func (f *formatter) writePairFootComments(pair *ast.KeyValueExpression) error {
-	for _, comment := range pair.FootComments {
+// writeFootComments handles writing foot comments after an object pair or
+// array element.
+func (f *formatter) writeFootComments(comments []*ast.Comment) error {
+	for _, comment := range comments {
 		if err := f.write("\n"); err != nil {
 			return err
 		}
 		if err := f.writeIndent(); err != nil {
 			return err
 		}
-		if err := f.write("# " + comment.Value); err != nil {
+		if err := f.write(commentLine(comment)); err != nil {
 			return err
 		}
 	}
@@ -252,28 +272,20 @@ func (f *formatter) writeObject(obj *ast.ObjectLiteral) error {
 	return f.write("}")
 }
 
-func (f *formatter) writePrettyArray(arr *ast.ArrayLiteral) error { //nolint:gocognit
+func (f *formatter) writePrettyArray(arr *ast.ArrayLiteral) error {
 	f.depth++
 	for i, elem := range arr.Elements {
-		if err := f.write("\n"); err != nil {
+		if err := f.writeItemPrefix(i, elem.NewlinesBefore, elem.HeadComments); err != nil {
 			return err
 		}
-		if err := f.writeIndent(); err != nil {
+		if err := f.writeNode(elem.Value); err != nil {
 			return err
 		}
-		if err := f.writeNode(elem); err != nil {
+		if err := f.writeItemSuffix(i, len(arr.Elements), elem.LineComment); err != nil {
 			return err
 		}
-		if f.opts.useFieldCommas {
-			if i < len(arr.Elements)-1 {
-				if err := f.write(","); err != nil {
-					return err
-				}
-			} else if f.opts.useTrailingCommas {
-				if err := f.write(","); err != nil {
-					return err
-				}
-			}
+		if err := f.writeFootComments(elem.FootComments); err != nil {
+			return err
 		}
 	}
 	f.depth--
@@ -290,13 +302,24 @@ func (f *formatter) writeCompactArray(arr *ast.ArrayLiteral) error {
 				return err
 			}
 		}
-		if err := f.writeNode(elem); err != nil {
+		if err := f.writeNode(elem.Value); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// hasElementComments reports whether any element of arr has a comment
+// attached, which an inlined array has no room for.
+func hasElementComments(arr *ast.ArrayLiteral) bool {
+	for _, elem := range arr.Elements {
+		if len(elem.HeadComments) > 0 || elem.LineComment != nil || len(elem.FootComments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (f *formatter) writeArray(arr *ast.ArrayLiteral) error {
 	if err := f.write("["); err != nil {
 		return err
@@ -304,7 +327,7 @@ func (f *formatter) writeArray(arr *ast.ArrayLiteral) error {
 
 	if len(arr.Elements) > 0 {
 		switch {
-		case f.opts.inlineArrays:
+		case f.opts.inlineArrays && (f.indent == "" || !hasElementComments(arr)):
 			if err := f.writeCompactArray(arr); err != nil {
 				return err
 			}
@@ -321,3 +344,159 @@ func (f *formatter) writeArray(arr *ast.ArrayLiteral) error {
 
 	return f.write("]")
 }
+
+// rootDoc returns the layout of a document or root value for the
+// MaxLineWidth option. A root object is always expanded.
+func (f *formatter) rootDoc(node ast.Node) (pretty.Doc, error) {
+	switch n := node.(type) {
+	case *ast.Document:
+		var docs []pretty.Doc
+		for _, comment := range n.HeadComments {
+			docs = append(docs, pretty.Text(commentLine(comment)), pretty.HardLine())
+		}
+		for i, stmt := range n.Statements {
+			doc, err := f.rootDoc(stmt)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				docs = append(docs, pretty.HardLine())
+			}
+			docs = append(docs, doc)
+		}
+		return pretty.Concat(docs...), nil
+	case *ast.ExpressionStatement:
+		return f.rootDoc(n.Expression)
+	case *ast.ObjectLiteral:
+		return f.objectDoc(n, true)
+	default:
+		return f.doc(n)
+	}
+}
+
+// doc returns the layout of a value for the MaxLineWidth option.
+func (f *formatter) doc(node ast.Node) (pretty.Doc, error) {
+	switch n := node.(type) {
+	case *ast.ObjectLiteral:
+		return f.objectDoc(n, false)
+	case *ast.ArrayLiteral:
+		return f.arrayDoc(n)
+	case *ast.StringLiteral:
+		if f.opts.inlineStrings || !strings.ContainsRune(n.Value, '\n') || strings.Contains(n.Value, `"""`) {
+			return pretty.Text(n.String()), nil
+		}
+		return pretty.Text(tripleQuote + "\n" + n.Value + tripleQuote), nil
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.BooleanLiteral:
+		return pretty.Text(n.TokenLiteral()), nil
+	case *ast.NullLiteral:
+		return pretty.Text("null"), nil
+	default:
+		return nil, fmt.Errorf("maml: unsupported node type for formatting: %T", n)
+	}
+}
+
+// layoutItem is an object pair or array element to be laid out.
+type layoutItem struct {
+	value          pretty.Doc
+	newlinesBefore int
+	headComments   []*ast.Comment
+	lineComment    *ast.Comment
+	footComments   []*ast.Comment
+}
+
+func (f *formatter) objectDoc(obj *ast.ObjectLiteral, expand bool) (pretty.Doc, error) {
+	if len(obj.Pairs) == 0 {
+		return pretty.Text("{}"), nil
+	}
+	items := make([]layoutItem, len(obj.Pairs))
+	for i, pair := range obj.Pairs {
+		value, err := f.doc(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = layoutItem{
+			value:          pretty.Concat(pretty.Text(pair.Key.String()+": "), value),
+			newlinesBefore: pair.NewlinesBefore,
+			headComments:   pair.HeadComments,
+			lineComment:    pair.LineComment,
+			footComments:   pair.FootComments,
+		}
+	}
+	doc := f.itemsDoc("{", "}", pretty.Line(), items)
+	if expand {
+		doc = pretty.Concat(pretty.BreakParent(), doc)
+	}
+	return pretty.Group(doc), nil
+}
+
+func (f *formatter) arrayDoc(arr *ast.ArrayLiteral) (pretty.Doc, error) {
+	if len(arr.Elements) == 0 {
+		return pretty.Text("[]"), nil
+	}
+	items := make([]layoutItem, len(arr.Elements))
+	for i, elem := range arr.Elements {
+		value, err := f.doc(elem.Value)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = layoutItem{
+			value:          value,
+			newlinesBefore: elem.NewlinesBefore,
+			headComments:   elem.HeadComments,
+			lineComment:    elem.LineComment,
+			footComments:   elem.FootComments,
+		}
+	}
+	if f.opts.inlineArrays && !hasElementComments(arr) {
+		docs := []pretty.Doc{pretty.Text("[")}
+		for i, item := range items {
+			if i > 0 {
+				docs = append(docs, pretty.Text(","))
+			}
+			docs = append(docs, item.value)
+		}
+		return pretty.Concat(append(docs, pretty.Text("]"))...), nil
+	}
+	return pretty.Group(f.itemsDoc("[", "]", pretty.SoftLine(), items)), nil
+}
+
+// itemsDoc lays out the items of an object or array between the opening and
+// closing brackets, with edge between each bracket and the items. On a single
+// line, the items are separated by ", ". Broken across lines, they keep their
+// comments and the blank lines between them, and are separated by commas only
+// with the UseFieldCommas option.
+func (f *formatter) itemsDoc(opening, closing string, edge pretty.Doc, items []layoutItem) pretty.Doc {
+	lineComma, lastComma := "", ""
+	if f.opts.useFieldCommas {
+		lineComma = ","
+		if f.opts.useTrailingCommas {
+			lastComma = ","
+		}
+	}
+
+	body := []pretty.Doc{edge}
+	for i, item := range items {
+		if i > 0 {
+			if item.newlinesBefore > 1 {
+				body = append(body, pretty.Text(strings.Repeat("\n", item.newlinesBefore-1)))
+			}
+			body = append(body, pretty.Line())
+		}
+		for _, comment := range item.headComments {
+			body = append(body, pretty.Text(commentLine(comment)), pretty.HardLine())
+		}
+		body = append(body, item.value)
+		if i < len(items)-1 {
+			body = append(body, pretty.IfBreak(pretty.Text(lineComma), pretty.Text(",")))
+		} else {
+			body = append(body, pretty.IfBreak(pretty.Text(lastComma), pretty.Text("")))
+		}
+		if item.lineComment != nil {
+			body = append(body, pretty.Text(" "+commentLine(item.lineComment)), pretty.BreakParent())
+		}
+		for _, comment := range item.footComments {
+			body = append(body, pretty.HardLine(), pretty.Text(commentLine(comment)))
+		}
+	}
+	return pretty.Concat(pretty.Text(opening), pretty.Nest(pretty.Concat(body...)), edge, pretty.Text(closing))
+}
@@ -5,8 +5,8 @@ import (
 	"errors"
 	"testing"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/KimNorgaard/go-maml/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -58,10 +58,10 @@ func TestFormatter(t *testing.T) {
 							Key: &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "arrayField"}, Value: "arrayField"},
 							Value: &ast.ArrayLiteral{
 								Token: token.Token{Type: token.LBRACK, Literal: "["},
-								Elements: []ast.Expression{
-									&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10},
-									&ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: `"foo"`}, Value: "foo"},
-									&ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}},
+								Elements: []*ast.ArrayElement{
+									{Value: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "10"}, Value: 10}},
+									{Value: &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: `"foo"`}, Value: "foo"}},
+									{Value: &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null"}}},
 								},
 							},
 						},
@@ -369,8 +369,8 @@ line two"""
 						Key: &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "innerArray"}, Value: "innerArray"},
 						Value: &ast.ArrayLiteral{
 							Token: token.Token{Type: token.LBRACK, Literal: "["},
-							Elements: []ast.Expression{
-								&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+							Elements: []*ast.ArrayElement{
+								{Value: &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1}},
 							},
 						},
 						LineComment: &ast.Comment{Value: "comment on array"},
@@ -387,6 +387,28 @@ line two"""
   ] # comment on array
 }`,
 		},
+		{
+			name: "InlineArrays with element comments keeps the array expanded",
+			node: &ast.ArrayLiteral{
+				Token: token.Token{Type: token.LBRACK, Literal: "["},
+				Elements: []*ast.ArrayElement{
+					{
+						HeadComments: []*ast.Comment{{Value: "first"}},
+						Value:        &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1},
+					},
+					{
+						Value:       &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2},
+						LineComment: &ast.Comment{Value: "second"},
+					},
+				},
+			},
+			opts: []Option{Indent(2), InlineArrays()},
+			expected: `[
+  # first
+  1
+  2 # second
+]`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -431,3 +453,142 @@ func TestFormatter_WriteErrors(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "write error")
 }
+
+func TestFormatter_MaxLineWidth(t *testing.T) {
+	src := `{
+  name: "api"
+  ports: [80, 443]
+  limits: {cpu: 2, memory: "1Gi"}
+  hosts: ["alpha.example.com", "beta.example.com", "gamma.example.com"]
+  matrix: [[1, 2], [3, 4]]
+
+  # Comments keep a value expanded
+  env: {
+    DEBUG: "1" # on
+  }
+  script: """
+echo hi
+"""
+  empty: {}
+}`
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name: "Width 40",
+			opts: []Option{MaxLineWidth(40)},
+			expected: `{
+  name: "api"
+  ports: [80, 443]
+  limits: { cpu: 2, memory: "1Gi" }
+  hosts: [
+    "alpha.example.com"
+    "beta.example.com"
+    "gamma.example.com"
+  ]
+  matrix: [[1, 2], [3, 4]]
+
+  # Comments keep a value expanded
+  env: {
+    DEBUG: "1" # on
+  }
+  script: """
+echo hi
+"""
+  empty: {}
+}`,
+		},
+		{
+			name: "Width 20 with field commas",
+			opts: []Option{MaxLineWidth(20), UseFieldCommas(), UseTrailingCommas()},
+			expected: `{
+  name: "api",
+  ports: [80, 443],
+  limits: {
+    cpu: 2,
+    memory: "1Gi",
+  },
+  hosts: [
+    "alpha.example.com",
+    "beta.example.com",
+    "gamma.example.com",
+  ],
+  matrix: [
+    [1, 2],
+    [3, 4],
+  ],
+
+  # Comments keep a value expanded
+  env: {
+    DEBUG: "1", # on
+  },
+  script: """
+echo hi
+""",
+  empty: {},
+}`,
+		},
+		{
+			name:     "Compact output ignores the width",
+			opts:     []Option{MaxLineWidth(40), Indent(0)},
+			expected: `{name:"api",ports:[80,443],limits:{cpu:2,memory:"1Gi"},hosts:["alpha.example.com","beta.example.com","gamma.example.com"],matrix:[[1,2],[3,4]],env:{DEBUG:"1"},script:"echo hi\n",empty:{}}`,
+		},
+		{
+			name: "Inline arrays",
+			opts: []Option{MaxLineWidth(20), InlineArrays()},
+			expected: `{
+  name: "api"
+  ports: [80,443]
+  limits: {
+    cpu: 2
+    memory: "1Gi"
+  }
+  hosts: ["alpha.example.com","beta.example.com","gamma.example.com"]
+  matrix: [[1,2],[3,4]]
+
+  # Comments keep a value expanded
+  env: {
+    DEBUG: "1" # on
+  }
+  script: """
+echo hi
+"""
+  empty: {}
+}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse([]byte(src))
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, NewEncoder(&buf, tc.opts...).Encode(doc))
+			require.Equal(t, tc.expected, buf.String())
+		})
+	}
+
+	t.Run("Root array and values", func(t *testing.T) {
+		out, err := Marshal([]any{1, map[string]any{"a": true}}, MaxLineWidth(80))
+		require.NoError(t, err)
+		require.Equal(t, `[1, { a: true }]`, string(out))
+
+		out, err = Marshal("text", MaxLineWidth(80))
+		require.NoError(t, err)
+		require.Equal(t, `"text"`, string(out))
+	})
+
+	t.Run("Inline arrays with element comments", func(t *testing.T) {
+		doc, err := Parse([]byte("{\n  ports: [\n    80 # http\n    443\n  ]\n}"))
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, NewEncoder(&buf, MaxLineWidth(80), InlineArrays()).Encode(doc))
+		require.Equal(t, "{\n  ports: [\n    80 # http\n    443\n  ]\n}", buf.String())
+	})
+
+	_, err := Marshal(1, MaxLineWidth(0))
+	require.EqualError(t, err, "maml: max line width must be a positive integer")
+}
@@ -8,7 +8,7 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/stretchr/testify/require"
 )
 
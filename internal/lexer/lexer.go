@@ -7,7 +7,7 @@ import (
 	"io"
 	"unicode/utf8"
 
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/token"
 )
 
 // Lexer holds the state for tokenizing MAML source.
@@ -15,6 +15,8 @@ type Lexer struct {
 	r      *bufio.Reader
 	buf    bytes.Buffer
 	ch     rune
+	size   int // size of ch in bytes
+	offset int // byte offset of ch
 	line   int
 	column int
 }
@@ -27,9 +29,20 @@ func New(r io.Reader) *Lexer {
 }
 
 // NextToken scans the input and returns the next token.
-func (l *Lexer) NextToken() token.Token { //nolint:gocognit
+func (l *Lexer) NextToken() token.Token {
+	tok := l.scan()
+	tok.End = l.pos()
+	return tok
+}
+
+// pos returns the position of the current character.
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.offset, Line: l.line, Column: l.column}
+}
+
+func (l *Lexer) scan() token.Token { //nolint:gocognit
 	l.skipWhitespace()
-	tok := token.Token{Line: l.line, Column: l.column}
+	tok := token.Token{Line: l.line, Column: l.column, Offset: l.offset}
 	switch l.ch {
 	case '{', '}', '[', ']', ',', ':':
 		tok.Type = token.Type(l.ch)
@@ -97,12 +110,14 @@ func (l *Lexer) NextToken() token.Token { //nolint:gocognit
 }
 
 func (l *Lexer) readRune() {
-	r, _, err := l.r.ReadRune()
+	r, size, err := l.r.ReadRune()
 	if err != nil {
 		l.ch = -1
+		l.size = 0
 		return
 	}
 	l.ch = r
+	l.size = size
 }
 
 func (l *Lexer) advance() {
@@ -110,6 +125,7 @@ func (l *Lexer) advance() {
 		l.line++
 		l.column = 0
 	}
+	l.offset += l.size
 	l.readRune()
 	l.column++
 }
@@ -7,7 +7,7 @@ import (
 
 	"github.com/KimNorgaard/go-maml/internal/lexer"
 	"github.com/KimNorgaard/go-maml/internal/testutil"
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -100,6 +100,36 @@ func TestNextToken(t *testing.T) {
 	}
 }
 
+func TestTokenPositions(t *testing.T) {
+	input := "{\r\n  \"naïve\": \"ø\" # ü\n  k: \"\"\"\nx\n\"\"\"\n}"
+	expected := []struct {
+		typ        token.Type
+		start, end token.Position
+	}{
+		{token.LBRACE, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 1, Line: 1, Column: 2}},
+		{token.NEWLINE, token.Position{Offset: 1, Line: 1, Column: 2}, token.Position{Offset: 3, Line: 2, Column: 1}},
+		{token.STRING, token.Position{Offset: 5, Line: 2, Column: 3}, token.Position{Offset: 13, Line: 2, Column: 10}},
+		{token.COLON, token.Position{Offset: 13, Line: 2, Column: 10}, token.Position{Offset: 14, Line: 2, Column: 11}},
+		{token.STRING, token.Position{Offset: 15, Line: 2, Column: 12}, token.Position{Offset: 19, Line: 2, Column: 15}},
+		{token.COMMENT, token.Position{Offset: 20, Line: 2, Column: 16}, token.Position{Offset: 24, Line: 2, Column: 19}},
+		{token.NEWLINE, token.Position{Offset: 24, Line: 2, Column: 19}, token.Position{Offset: 25, Line: 3, Column: 1}},
+		{token.IDENT, token.Position{Offset: 27, Line: 3, Column: 3}, token.Position{Offset: 28, Line: 3, Column: 4}},
+		{token.COLON, token.Position{Offset: 28, Line: 3, Column: 4}, token.Position{Offset: 29, Line: 3, Column: 5}},
+		{token.STRING, token.Position{Offset: 30, Line: 3, Column: 6}, token.Position{Offset: 39, Line: 5, Column: 4}},
+		{token.NEWLINE, token.Position{Offset: 39, Line: 5, Column: 4}, token.Position{Offset: 40, Line: 6, Column: 1}},
+		{token.RBRACE, token.Position{Offset: 40, Line: 6, Column: 1}, token.Position{Offset: 41, Line: 6, Column: 2}},
+		{token.EOF, token.Position{Offset: 41, Line: 6, Column: 2}, token.Position{Offset: 41, Line: 6, Column: 2}},
+	}
+
+	l := lexer.New(strings.NewReader(input))
+	for i, tt := range expected {
+		tok := l.NextToken()
+		require.Equal(t, tt.typ, tok.Type, "test[%d] - wrong token type", i)
+		require.Equal(t, tt.start, tok.Pos(), "test[%d] - wrong start position", i)
+		require.Equal(t, tt.end, tok.End, "test[%d] - wrong end position", i)
+	}
+}
+
 func TestStringEscapes(t *testing.T) {
 	tests := []struct {
 		input     string
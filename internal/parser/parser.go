@@ -2,13 +2,14 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"slices"
 	"strconv"
 
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/KimNorgaard/go-maml/errors"
-	"github.com/KimNorgaard/go-maml/internal/ast"
 	"github.com/KimNorgaard/go-maml/internal/lexer"
-	"github.com/KimNorgaard/go-maml/internal/token"
+	"github.com/KimNorgaard/go-maml/token"
 )
 
 type prefixParseFn func() ast.Expression
@@ -34,6 +35,16 @@ type Parser struct {
 	prefixParseFns map[token.Type]prefixParseFn
 
 	parseComments bool
+
+	// recovering is set after a syntax error and cleared when the parser
+	// starts on the next object pair or array element, or closes an object or
+	// array. Errors reported while recovering are follow-on errors of the
+	// first one and are dropped.
+	recovering bool
+
+	// closers holds the closing token types of the objects and arrays
+	// currently being parsed, innermost last.
+	closers []token.Type
 }
 
 // New creates a new parser.
@@ -72,6 +83,11 @@ func (p *Parser) Errors() errors.ParseErrors {
 }
 
 // Parse parses the MAML document and returns the root AST node.
+//
+// Parsing continues after syntax errors: the invalid source is skipped up to
+// the next newline, comma or closing bracket and replaced by an
+// ast.BadExpression, so a partial document is returned along with the errors
+// reported by Errors.
 func (p *Parser) Parse() *ast.Document {
 	document := &ast.Document{}
 	document.Statements = []ast.Statement{}
@@ -96,12 +112,51 @@ func (p *Parser) Parse() *ast.Document {
 	p.skip(token.NEWLINE)
 
 	if !p.curTokenIs(token.EOF) {
-		p.appendError(fmt.Sprintf("unexpected token after main value: %s ('%s')", p.curToken.Type, p.curToken.Literal))
+		p.appendErrorAt(p.curToken.Pos(), p.curToken.End, fmt.Sprintf("unexpected token after main value: %s ('%s')", p.curToken.Type, p.curToken.Literal))
+		p.parseTrailing(stmt.Expression)
 	}
 
 	return document
 }
 
+// parseTrailing parses the input after the main value as more pairs or
+// elements of it and discards them. This happens when a stray closing bracket
+// ends the main value early; the rest of the input is still checked so that
+// its errors are reported too.
+func (p *Parser) parseTrailing(main ast.Expression) {
+	closer := token.RBRACE
+	if _, ok := main.(*ast.ArrayLiteral); ok {
+		closer = token.RBRACK
+	}
+	p.closers = append(p.closers, closer)
+	defer func() { p.closers = p.closers[:len(p.closers)-1] }()
+
+	for {
+		p.skip(token.COMMA, token.NEWLINE)
+		if p.curTokenIs(token.EOF) {
+			return
+		}
+		start := p.curToken
+		switch main.(type) {
+		case *ast.ObjectLiteral:
+			if p.curTokenIs(token.LBRACE) {
+				// A second object rather than more pairs.
+				p.parseExpression()
+			} else {
+				p.parseObjectPairs()
+			}
+		case *ast.ArrayLiteral:
+			p.parseArrayElements()
+		default:
+			p.sync()
+		}
+		if p.curToken == start {
+			// Skip the closing bracket that matches the stray one.
+			p.nextToken()
+		}
+	}
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
@@ -142,7 +197,7 @@ func (p *Parser) consumeNewlines() int {
 	return count
 }
 
-func (p *Parser) parseStatement() ast.Statement {
+func (p *Parser) parseStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression()
 	return stmt
@@ -151,12 +206,43 @@ func (p *Parser) parseStatement() ast.Statement {
 func (p *Parser) parseExpression() ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
+		start := p.curToken
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return p.bad(start)
 	}
 	return prefix()
 }
 
+// bad skips the rest of an invalid construct that started at start and
+// returns a BadExpression covering it.
+func (p *Parser) bad(start token.Token) ast.Expression {
+	p.sync()
+	return &ast.BadExpression{From: start.Pos(), To: p.curToken.Pos()}
+}
+
+// sync skips tokens up to the next newline, comma or closing bracket at the
+// current nesting level, or the end of input. Brackets opened while skipping
+// are skipped along with their contents.
+func (p *Parser) sync() {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE, token.LBRACK:
+			depth++
+		case token.RBRACE, token.RBRACK:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case token.NEWLINE, token.COMMA:
+			if depth == 0 {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
 // The contract for all parse functions is that they are entered with p.curToken
 // being the first token of the construct, and they must return with p.curToken
 // pointing to the token *after* the construct.
@@ -169,9 +255,10 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	if len(lit) > 0 {
 		firstChar := lit[0]
 		if (firstChar >= '0' && firstChar <= '9') || firstChar == '-' {
+			start := p.curToken
 			p.appendError(fmt.Sprintf("invalid number format: %s", lit))
 			p.nextToken()
-			return nil
+			return p.bad(start)
 		}
 	}
 
@@ -183,10 +270,17 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		// Integers outside the int64 range are kept as big.Int.
+		if lit.Big, _ = new(big.Int).SetString(p.curToken.Literal, 10); lit.Big != nil {
+			p.nextToken()
+			return lit
+		}
+	}
 	if err != nil {
 		p.appendError(fmt.Sprintf("could not parse %q as integer: %s", p.curToken.Literal, err))
 		p.nextToken()
-		return nil
+		return p.bad(lit.Token)
 	}
 	lit.Value = value
 	p.nextToken()
@@ -199,7 +293,7 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	if err != nil {
 		p.appendError(fmt.Sprintf("could not parse %q as float: %s", p.curToken.Literal, err))
 		p.nextToken()
-		return nil
+		return p.bad(lit.Token)
 	}
 	lit.Value = value
 	p.nextToken()
@@ -225,74 +319,178 @@ func (p *Parser) parseNullLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIllegal() ast.Expression {
+	start := p.curToken
 	p.appendError(fmt.Sprintf("illegal token encountered: %s", p.curToken.Literal))
 	p.nextToken()
-	return nil
+	return p.bad(start)
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	p.nextToken() // Consume '['
+	p.closers = append(p.closers, token.RBRACK)
+	defer func() { p.closers = p.closers[:len(p.closers)-1] }()
 
-	array.Elements = p.parseExpressionList(token.RBRACK)
+	array.Elements = p.parseArrayElements()
 
 	if !p.curTokenIs(token.RBRACK) {
+		// Leave a mismatched '}' for the enclosing object to close.
 		p.appendError(fmt.Sprintf("unterminated array literal, expected ']' got %s", p.curToken.Type))
-		return nil
+		return array
 	}
+	array.EndToken = p.curToken
 	p.nextToken() // Consume ']'
+	// The array is closed, so later errors are independent of any error
+	// inside it.
+	p.recovering = false
 	return array
 }
 
-func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
-	list := []ast.Expression{}
-	p.skip(token.NEWLINE)
-	if p.curTokenIs(end) {
-		return list
-	}
+func (p *Parser) parseArrayElements() []*ast.ArrayElement {
+	elements := []*ast.ArrayElement{}
+	first := true
+
+	for !p.atClosingToken() {
+		newlines := p.consumeNewlines()
+		// Elements are separated by commas or newlines. Only the first
+		// element may not be preceded by a comma.
+		for !first && (p.curTokenIs(token.COMMA) || p.curTokenIs(token.NEWLINE)) {
+			if p.curTokenIs(token.NEWLINE) {
+				newlines++
+			}
+			p.nextToken()
+		}
 
-	list = append(list, p.parseExpression())
+		if p.atClosingToken() {
+			break
+		}
 
-	for {
-		p.skip(token.NEWLINE, token.COMMA)
-		if p.curTokenIs(end) || p.curTokenIs(token.EOF) {
+		headComments := p.parseHeadComments()
+
+		if p.atClosingToken() || p.atPair() {
 			break
 		}
-		list = append(list, p.parseExpression())
+
+		first = false
+		p.recovering = false
+		start := p.curToken
+		value := p.parseExpression()
+		if p.curToken == start {
+			// Make sure the parser always makes progress on invalid input.
+			p.nextToken()
+			continue
+		}
+
+		elem := &ast.ArrayElement{Value: value, HeadComments: headComments, NewlinesBefore: newlines}
+		elem.LineComment = p.parseLineComment()
+		elem.FootComments = p.parseFootComments()
+		elements = append(elements, elem)
 	}
-	return list
+	return elements
 }
 
-func (p *Parser) parseObjectLiteral() ast.Expression { //nolint:gocognit
-	obj := &ast.ObjectLiteral{Token: p.curToken, Pairs: []*ast.KeyValueExpression{}}
-	keys := make(map[string]bool)
+// parseHeadComments consumes the comment blocks preceding an object pair or
+// array element. It returns nil when comments are not being parsed.
+func (p *Parser) parseHeadComments() []*ast.Comment {
+	if !p.parseComments {
+		return nil
+	}
+	var comments []*ast.Comment
+	// A value can be preceded by multiple comment blocks, separated by
+	// newlines. We need to consume all of them.
+	for p.curTokenIs(token.COMMENT) {
+		comments = append(comments, p.consumeComments()...)
+		p.skip(token.NEWLINE)
+	}
+	return comments
+}
+
+// parseLineComment consumes a comment on the same line as the value that was
+// just parsed. It returns nil when there is none or comments are not being
+// parsed.
+func (p *Parser) parseLineComment() *ast.Comment {
+	if !p.parseComments {
+		return nil
+	}
+	// A line comment must not be separated by a newline from the value.
+	// It can appear before or after an optional comma.
+	if p.curTokenIs(token.COMMA) && p.peekTokenIs(token.COMMENT) {
+		// Case: `value, # comment`. Here we consume the comma as well
+		// as it is part of the "line" that the comment is on.
+		p.nextToken() // consume comma
+	}
+	if !p.curTokenIs(token.COMMENT) {
+		return nil
+	}
+	// Case: `value # comment`
+	comment := &ast.Comment{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken() // consume comment
+	return comment
+}
+
+// parseFootComments consumes the comment block directly following an object
+// pair or array element. It returns nil when there is none or comments are
+// not being parsed.
+func (p *Parser) parseFootComments() []*ast.Comment {
+	if !p.parseComments {
+		return nil
+	}
+	// Foot comments may be preceded by an optional comma.
+	if p.curTokenIs(token.COMMA) && p.peekTokenIs(token.NEWLINE) {
+		p.nextToken() // consume comma
+	}
+	// A foot comment must be on a new line.
+	if p.curTokenIs(token.NEWLINE) && p.peekTokenIs(token.COMMENT) {
+		p.nextToken() // consume newline
+		return p.consumeComments()
+	}
+	return nil
+}
+
+func (p *Parser) parseObjectLiteral() ast.Expression {
+	obj := &ast.ObjectLiteral{Token: p.curToken}
 	p.nextToken() // Consume '{'
+	p.closers = append(p.closers, token.RBRACE)
+	defer func() { p.closers = p.closers[:len(p.closers)-1] }()
+
+	obj.Pairs = p.parseObjectPairs()
+
+	if !p.curTokenIs(token.RBRACE) {
+		// Leave a mismatched ']' for the enclosing array to close.
+		p.appendError(fmt.Sprintf("unterminated object literal, expected '}' got %s", p.curToken.Type))
+		return obj
+	}
+	obj.EndToken = p.curToken
+	p.nextToken() // Consume '}'
+	// The object is closed, so later errors are independent of any error
+	// inside it.
+	p.recovering = false
+	return obj
+}
+
+func (p *Parser) parseObjectPairs() []*ast.KeyValueExpression { //nolint:gocognit
+	pairs := []*ast.KeyValueExpression{}
+	keys := make(map[string]bool)
 
-	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+	for !p.atClosingToken() {
 		newlines := p.consumeNewlines()
 		if p.curTokenIs(token.COMMA) {
 			p.nextToken()
 			newlines += p.consumeNewlines()
 		}
 
-		if p.curTokenIs(token.RBRACE) {
+		if p.atClosingToken() {
 			break
 		}
 
-		var headComments []*ast.Comment
-		if p.parseComments {
-			// A key-value pair can be preceded by multiple comment blocks,
-			// separated by newlines. We need to consume all of them.
-			for p.curTokenIs(token.COMMENT) {
-				headComments = append(headComments, p.consumeComments()...)
-				p.skip(token.NEWLINE)
-			}
-		}
+		headComments := p.parseHeadComments()
 
-		if p.curTokenIs(token.RBRACE) {
+		if p.atClosingToken() {
 			break
 		}
 
+		p.recovering = false
+		start := p.curToken
 		pair := p.parseKeyValuePair(headComments, newlines)
 		if pair != nil {
 			var keyStr string
@@ -304,34 +502,21 @@ func (p *Parser) parseObjectLiteral() ast.Expression { //nolint:gocognit
 			}
 
 			if keys[keyStr] {
-				p.appendError(fmt.Sprintf("duplicate key in object: %s", keyStr))
+				p.appendErrorAt(pair.Key.Pos(), pair.Key.End(), fmt.Sprintf("duplicate key in object: %s", keyStr))
 			}
 			keys[keyStr] = true
-			obj.Pairs = append(obj.Pairs, pair)
+			pairs = append(pairs, pair)
 			// After parsing a pair, check for foot comments that might follow.
-			if p.parseComments {
-				// After parsing a pair, check for foot comments that might follow,
-				// which may be preceded by an optional comma.
-				if p.curTokenIs(token.COMMA) && p.peekTokenIs(token.NEWLINE) {
-					p.nextToken() // consume comma
-				}
-				// A foot comment must be on a new line.
-				if p.curTokenIs(token.NEWLINE) && p.peekTokenIs(token.COMMENT) {
-					p.nextToken() // consume newline
-					pair.FootComments = p.consumeComments()
-				}
-			}
+			pair.FootComments = p.parseFootComments()
 		} else {
+			p.sync()
+		}
+		if p.curToken == start {
+			// Make sure the parser always makes progress on invalid input.
 			p.nextToken()
 		}
 	}
-
-	if !p.curTokenIs(token.RBRACE) {
-		p.appendError(fmt.Sprintf("unterminated object literal, expected '}' got %s", p.curToken.Type))
-		return nil
-	}
-	p.nextToken() // Consume '}'
-	return obj
+	return pairs
 }
 
 func (p *Parser) parseKeyValuePair(headComments []*ast.Comment, newlinesBefore int) *ast.KeyValueExpression {
@@ -341,34 +526,18 @@ func (p *Parser) parseKeyValuePair(headComments []*ast.Comment, newlinesBefore i
 	}
 
 	if !p.curTokenIs(token.COLON) {
+		start := p.curToken
 		p.appendError(fmt.Sprintf("expected ':' after key, got %s", p.curToken.Type))
-		return nil
+		return &ast.KeyValueExpression{Key: key, Value: p.bad(start), HeadComments: headComments, NewlinesBefore: newlinesBefore}
 	}
+	colon := p.curToken
 	p.nextToken() // Consume ':'
 	p.skip(token.NEWLINE)
 
 	value := p.parseExpression()
-	if value == nil {
-		return nil
-	}
 
-	kvp := &ast.KeyValueExpression{Key: key, Value: value, HeadComments: headComments, NewlinesBefore: newlinesBefore}
-
-	if p.parseComments {
-		// A line comment must not be separated by a newline from the value.
-		// It can appear before or after an optional comma.
-		if p.curTokenIs(token.COMMENT) {
-			// Case: `key: value # comment`
-			kvp.LineComment = &ast.Comment{Token: p.curToken, Value: p.curToken.Literal}
-			p.nextToken() // consume comment
-		} else if p.curTokenIs(token.COMMA) && p.peekTokenIs(token.COMMENT) {
-			// Case: `key: value, # comment`. Here we consume the comma as well
-			// as it is part of the "line" that the comment is on.
-			p.nextToken() // consume comma
-			kvp.LineComment = &ast.Comment{Token: p.curToken, Value: p.curToken.Literal}
-			p.nextToken() // consume comment
-		}
-	}
+	kvp := &ast.KeyValueExpression{Token: colon, Key: key, Value: value, HeadComments: headComments, NewlinesBefore: newlinesBefore}
+	kvp.LineComment = p.parseLineComment()
 
 	return kvp
 }
@@ -385,7 +554,6 @@ func (p *Parser) parseObjectKey() ast.Expression {
 		p.nextToken()
 	default:
 		p.appendError(fmt.Sprintf("invalid token for object key: %s ('%s')", p.curToken.Type, p.curToken.Literal))
-		p.nextToken()
 		return nil
 	}
 	return key
@@ -411,8 +579,52 @@ func (p *Parser) noPrefixParseFnError(t token.Type) {
 	p.appendError(msg)
 }
 
+// appendError records a syntax error at the current token and puts the
+// parser in recovery mode. It is dropped if the parser is already
+// recovering from an earlier error.
 func (p *Parser) appendError(msg string) {
-	p.errors = append(p.errors, errors.ParseError{Message: msg, Line: p.curToken.Line, Column: p.curToken.Column})
+	if p.recovering {
+		return
+	}
+	p.recovering = true
+	p.appendErrorAt(p.curToken.Pos(), p.curToken.End, msg)
+}
+
+// appendErrorAt records an error for the source between start and end.
+func (p *Parser) appendErrorAt(start, end token.Position, msg string) {
+	p.errors = append(p.errors, errors.ParseError{
+		Message: msg,
+		Line:    start.Line,
+		Column:  start.Column,
+		Offset:  start.Offset,
+		End:     end,
+	})
+}
+
+// atClosingToken reports whether the current token ends the innermost object
+// or array. A mismatched closing bracket that closes an enclosing container
+// ends it too, so that the enclosing container can recover. Stray closing
+// brackets are not closing tokens; they are reported and skipped like any
+// other unexpected token.
+func (p *Parser) atClosingToken() bool {
+	switch p.curToken.Type {
+	case token.EOF:
+		return true
+	case token.RBRACE, token.RBRACK:
+		return slices.Contains(p.closers, p.curToken.Type)
+	}
+	return false
+}
+
+// atPair reports whether the current token starts a key-value pair inside an
+// array that is nested in an object. The array was not terminated, and the
+// pair belongs to the enclosing object.
+func (p *Parser) atPair() bool {
+	switch p.curToken.Type {
+	case token.IDENT, token.STRING, token.INT:
+		return p.peekTokenIs(token.COLON) && slices.Contains(p.closers, token.RBRACE)
+	}
+	return false
 }
 
 func (p *Parser) curTokenIs(t token.Type) bool {
@@ -2,14 +2,16 @@ package parser_test
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/KimNorgaard/go-maml/internal/testutil"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/KimNorgaard/go-maml/internal/lexer"
 	"github.com/KimNorgaard/go-maml/internal/parser"
+	"github.com/KimNorgaard/go-maml/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -96,9 +98,9 @@ func TestArrayLiteralParsing(t *testing.T) {
 	require.Len(t, array.Elements, 3, "len(array.Elements) not 3")
 
 	// Test elements inside the array
-	testLiteralExpression(t, array.Elements[0], int64(1))
-	testLiteralExpression(t, array.Elements[1], "two")
-	testLiteralExpression(t, array.Elements[2], true)
+	testLiteralExpression(t, array.Elements[0].Value, int64(1))
+	testLiteralExpression(t, array.Elements[1].Value, "two")
+	testLiteralExpression(t, array.Elements[2].Value, true)
 }
 
 func TestObjectLiteralParsing(t *testing.T) {
@@ -177,8 +179,8 @@ func TestArrayWithOptionalCommas(t *testing.T) {
 			arr, ok := stmt.Expression.(*ast.ArrayLiteral)
 			require.True(t, ok)
 			require.Len(t, arr.Elements, 2)
-			testLiteralExpression(t, arr.Elements[0], "one")
-			testLiteralExpression(t, arr.Elements[1], "two")
+			testLiteralExpression(t, arr.Elements[0].Value, "one")
+			testLiteralExpression(t, arr.Elements[1].Value, "two")
 		})
 	}
 }
@@ -422,45 +424,31 @@ func TestObjectParsingScenarios(t *testing.T) {
 	}
 }
 
-func TestIntegerOverflow(t *testing.T) {
+func TestBigIntegerLiterals(t *testing.T) {
 	tests := []struct {
-		name        string
-		input       string
-		shouldError bool
+		name  string
+		input string
+		big   bool
 	}{
-		{
-			name:        "int64 max",
-			input:       "9223372036854775807",
-			shouldError: false,
-		},
-		{
-			name:        "int64 overflow",
-			input:       "9223372036854775808",
-			shouldError: true,
-		},
-		{
-			name:        "int64 min",
-			input:       "-9223372036854775808",
-			shouldError: false,
-		},
-		{
-			name:        "int64 underflow",
-			input:       "-9223372036854775809",
-			shouldError: true,
-		},
+		{name: "int64 max", input: "9223372036854775807"},
+		{name: "int64 overflow", input: "9223372036854775808", big: true},
+		{name: "int64 min", input: "-9223372036854775808"},
+		{name: "int64 underflow", input: "-9223372036854775809", big: true},
+		{name: "Beyond uint64", input: "123456789012345678901234567890", big: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			l := lexer.New(strings.NewReader(tt.input))
 			p := parser.New(l)
-			p.Parse()
+			doc := p.Parse()
+			require.Empty(t, p.Errors(), "parser has errors for %s", tt.name)
 
-			if tt.shouldError {
-				require.NotEmpty(t, p.Errors(), "expected parser errors for %s", tt.name)
-			} else {
-				require.Empty(t, p.Errors(), "parser has errors for %s", tt.name)
-			}
+			lit, ok := doc.Root().(*ast.IntegerLiteral)
+			require.True(t, ok)
+			require.Equal(t, tt.big, lit.Big != nil)
+			require.Equal(t, tt.input, lit.BigInt().String())
+			require.Equal(t, tt.input, lit.String())
 		})
 	}
 }
@@ -696,6 +684,132 @@ func TestSyntaxErrors(t *testing.T) {
 	}
 }
 
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string // errors as "line:column message"
+		doc      string   // String() of the partial document
+	}{
+		{
+			name:     "Independent errors on separate lines",
+			input:    "{\n  a: 1x\n  b: \"ok\"\n  c: \"unterminated\n  d: @\n  e: true\n}",
+			expected: []string{"2:6 invalid number format: 1x", "4:6 illegal token encountered: unterminated string", "5:6 illegal token encountered: @"},
+			doc:      `{a:<bad expression>, b:"ok", c:<bad expression>, d:<bad expression>, e:true}`,
+		},
+		{
+			name:     "Rest of the line is skipped",
+			input:    "{ a: 1x 2 [3, 4], b: 5 }",
+			expected: []string{"1:6 invalid number format: 1x"},
+			doc:      "{a:<bad expression>, b:5}",
+		},
+		{
+			name:     "Missing colon",
+			input:    `{ "key" 1, other: 2 }`,
+			expected: []string{"1:9 expected ':' after key, got INT"},
+			doc:      `{"key":<bad expression>, other:2}`,
+		},
+		{
+			name:     "Missing value",
+			input:    "{ a: , b: 1 }",
+			expected: []string{"1:6 no prefix parse function for , (',') found"},
+			doc:      "{a:<bad expression>, b:1}",
+		},
+		{
+			name:     "Invalid key with nested brackets",
+			input:    "{ [1, 2]: 3, b: 4 }",
+			expected: []string{"1:3 invalid token for object key: [ ('[')"},
+			doc:      "{b:4}",
+		},
+		{
+			name:     "Array elements",
+			input:    "[1, 2x, {a: }, 4]",
+			expected: []string{"1:5 invalid number format: 2x", "1:13 no prefix parse function for } ('}') found"},
+			doc:      "[1, <bad expression>, {a:<bad expression>}, 4]",
+		},
+		{
+			name:     "Mismatched closing bracket closes enclosing object",
+			input:    "{ a: [1, 2 }",
+			expected: []string{"1:12 unterminated array literal, expected ']' got }"},
+			doc:      "{a:[1, 2]}",
+		},
+		{
+			name:     "Stray closing bracket is skipped",
+			input:    "{\n  a: {\n    b: 1\n  ]\n  c: 2\n  }\n}",
+			expected: []string{"4:3 invalid token for object key: ] (']')"},
+			doc:      "{a:{b:1, c:2}}",
+		},
+		{
+			name:     "Unterminated containers report once",
+			input:    "{ a: [1, 2",
+			expected: []string{"1:11 unterminated array literal, expected ']' got EOF"},
+			doc:      "{a:[1, 2]}",
+		},
+		{
+			name:     "Unterminated array before a pair",
+			input:    "{\n  a: [1, 2\n  b: 3x\n  c: true\n}",
+			expected: []string{"3:3 unterminated array literal, expected ']' got IDENT", "3:6 invalid number format: 3x"},
+			doc:      "{a:[1, 2], b:<bad expression>, c:true}",
+		},
+		{
+			name:  "Main value closed early",
+			input: "{\n  a: 1\n  b: }\n  c: [1, 2\n  e: @\n}",
+			expected: []string{
+				"3:6 no prefix parse function for } ('}') found",
+				"4:3 unexpected token after main value: IDENT ('c')",
+				"5:3 unterminated array literal, expected ']' got IDENT",
+				"5:6 illegal token encountered: @",
+			},
+			doc: "{a:1, b:<bad expression>}",
+		},
+		{
+			name:     "Mismatched closing bracket closes the main value",
+			input:    "{ a: [1, 2}, b: 3 }",
+			expected: []string{"1:11 unterminated array literal, expected ']' got }", "1:12 unexpected token after main value: , (',')"},
+			doc:      "{a:[1, 2]}",
+		},
+		{
+			name:     "Second value after the main value",
+			input:    "{a: 1} {b: 2x}",
+			expected: []string{"1:8 unexpected token after main value: { ('{')", "1:12 invalid number format: 2x"},
+			doc:      "{a:1}",
+		},
+		{
+			name:     "Illegal character in array",
+			input:    "[1, 2)",
+			expected: []string{"1:6 illegal token encountered: )"},
+			doc:      "[1, 2, <bad expression>]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New(strings.NewReader(tt.input))
+			p := parser.New(l)
+			doc := p.Parse()
+
+			var errs []string
+			for _, e := range p.Errors() {
+				errs = append(errs, fmt.Sprintf("%d:%d %s", e.Line, e.Column, e.Message))
+			}
+			require.Equal(t, tt.expected, errs)
+			require.Equal(t, tt.doc, doc.String())
+		})
+	}
+}
+
+func TestErrorRecovery_BadExpressionSpan(t *testing.T) {
+	input := "{ a: 1x 2, b: 3 }"
+	l := lexer.New(strings.NewReader(input))
+	p := parser.New(l)
+	doc := p.Parse()
+	require.Len(t, p.Errors(), 1)
+
+	bad, ok := doc.Root().(*ast.ObjectLiteral).Pairs[0].Value.(*ast.BadExpression)
+	require.True(t, ok)
+	require.Equal(t, "1x 2", input[bad.Pos().Offset:bad.End().Offset])
+}
+
 func TestObjectParsingWithComments(t *testing.T) {
 	input := `{
 	  # Head comment for key1
@@ -779,6 +893,105 @@ func TestLineCommentAfterComma(t *testing.T) {
 	require.Empty(t, pair2.HeadComments, "key2 should not have the comment from key1 as a head comment")
 }
 
+func TestArrayParsingWithComments(t *testing.T) {
+	input := `[
+	  # staging host
+	  "staging.example.com", # line comment
+
+	  "prod.example.com"
+	  # foot comment for prod
+	  # second foot line
+
+	  # head comment for last
+	  "dev.example.com"
+	]`
+
+	l := lexer.New(strings.NewReader(input))
+	p := parser.New(l, parser.WithParseComments())
+	doc := p.Parse()
+
+	require.Empty(t, p.Errors(), "parser should not have errors")
+
+	stmt, ok := doc.Statements[0].(*ast.ExpressionStatement)
+	require.True(t, ok)
+
+	arr, ok := stmt.Expression.(*ast.ArrayLiteral)
+	require.True(t, ok)
+	require.Len(t, arr.Elements, 3)
+
+	elem1 := arr.Elements[0]
+	testLiteralExpression(t, elem1.Value, "staging.example.com")
+	require.Len(t, elem1.HeadComments, 1)
+	require.Equal(t, "staging host", elem1.HeadComments[0].Value)
+	require.NotNil(t, elem1.LineComment)
+	require.Equal(t, "line comment", elem1.LineComment.Value)
+	require.Empty(t, elem1.FootComments)
+
+	elem2 := arr.Elements[1]
+	require.Equal(t, 2, elem2.NewlinesBefore)
+	require.Empty(t, elem2.HeadComments)
+	require.Nil(t, elem2.LineComment)
+	require.Len(t, elem2.FootComments, 2)
+	require.Equal(t, "foot comment for prod", elem2.FootComments[0].Value)
+	require.Equal(t, "second foot line", elem2.FootComments[1].Value)
+
+	elem3 := arr.Elements[2]
+	require.Len(t, elem3.HeadComments, 1)
+	require.Equal(t, "head comment for last", elem3.HeadComments[0].Value)
+	require.Empty(t, elem3.FootComments)
+}
+
+func TestArrayParsingWithoutComments(t *testing.T) {
+	input := "[\n  # ignored\n  1, # ignored\n  2\n]"
+
+	l := lexer.New(strings.NewReader(input))
+	p := parser.New(l)
+	doc := p.Parse()
+
+	require.Empty(t, p.Errors())
+	arr, ok := doc.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral)
+	require.True(t, ok)
+	require.Len(t, arr.Elements, 2)
+	require.Nil(t, arr.Elements[0].HeadComments)
+	require.Nil(t, arr.Elements[0].LineComment)
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "# head\n{\n  spec: {\n    ports: [80, \"é\"] # web\n  }\n}\n"
+
+	l := lexer.New(strings.NewReader(input))
+	p := parser.New(l, parser.WithParseComments())
+	doc := p.Parse()
+	require.Empty(t, p.Errors())
+
+	text := func(n ast.Node) string {
+		return input[n.Pos().Offset:n.End().Offset]
+	}
+
+	root, ok := doc.Root().(*ast.ObjectLiteral)
+	require.True(t, ok)
+	require.Equal(t, input[7:len(input)-1], text(root))
+	require.Equal(t, token.Position{Offset: 7, Line: 2, Column: 1}, doc.Pos())
+	require.Equal(t, token.Position{Offset: len(input) - 1, Line: 6, Column: 2}, doc.End())
+
+	spec := root.Pairs[0]
+	require.Equal(t, ":", spec.Token.Literal)
+	require.Equal(t, token.Position{Offset: 15, Line: 3, Column: 7}, spec.Token.Pos())
+	require.Equal(t, "spec: {\n    ports: [80, \"é\"] # web\n  }", text(spec))
+
+	ports := spec.Value.(*ast.ObjectLiteral).Pairs[0]
+	arr, ok := ports.Value.(*ast.ArrayLiteral)
+	require.True(t, ok)
+	require.Equal(t, `[80, "é"]`, text(arr))
+	require.Equal(t, "]", arr.EndToken.Literal)
+	require.Equal(t, "80", text(arr.Elements[0]))
+	require.Equal(t, `"é"`, text(arr.Elements[1].Value))
+	require.Equal(t, token.Position{Offset: 35, Line: 4, Column: 17}, arr.Elements[1].Pos())
+	require.Equal(t, 39, arr.Elements[1].End().Offset)
+	require.Equal(t, 20, arr.Elements[1].End().Column, "columns are counted in runes")
+	require.Equal(t, "# web", text(ports.LineComment))
+}
+
 func BenchmarkParse(b *testing.B) {
 	benchmarkInput, err := testutil.ReadTestData("large.maml")
 	require.NoError(b, err)
@@ -0,0 +1,186 @@
+// Package pretty implements a line-width-aware pretty printer in the style of
+// Wadler's "A prettier printer" and Prettier.
+//
+// A document is built from text, line breaks, indentation and groups. When a
+// document is rendered, each group is printed on a single line if it fits
+// within the maximum width, with its lines printed as spaces (Line) or
+// nothing (SoftLine); otherwise all of the group's own lines become newlines.
+// Groups nested in a broken group are laid out again on their own.
+package pretty
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Doc is a document to be rendered.
+type Doc interface {
+	// hard reports whether the document contains a line break that is
+	// always printed, which forces the groups around it to break.
+	hard() bool
+}
+
+type (
+	text   string
+	line   struct{ soft, forced bool }
+	concat []Doc
+	nest   struct{ doc Doc }
+	group  struct {
+		doc    Doc
+		broken bool // doc contains a forced line break
+	}
+	ifBreak     struct{ broken, flat Doc }
+	breakParent struct{}
+)
+
+func (t text) hard() bool      { return strings.ContainsRune(string(t), '\n') }
+func (l line) hard() bool      { return l.forced }
+func (n nest) hard() bool      { return n.doc.hard() }
+func (g group) hard() bool     { return g.broken }
+func (b ifBreak) hard() bool   { return b.broken.hard() }
+func (breakParent) hard() bool { return true }
+func (c concat) hard() bool {
+	for _, d := range c {
+		if d.hard() {
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns a document that prints s as is. Text containing a newline
+// forces the enclosing groups to break; the newline is printed without
+// indentation, as in a multiline string.
+func Text(s string) Doc { return text(s) }
+
+// Line returns a line break that is printed as a space if its group fits on
+// one line.
+func Line() Doc { return line{} }
+
+// SoftLine returns a line break that is printed as nothing if its group fits
+// on one line.
+func SoftLine() Doc { return line{soft: true} }
+
+// HardLine returns a line break that is always printed and forces the
+// enclosing groups to break.
+func HardLine() Doc { return line{forced: true} }
+
+// BreakParent returns an empty document that forces the enclosing groups to
+// break, e.g. after a comment that runs to the end of the line.
+func BreakParent() Doc { return breakParent{} }
+
+// Concat returns the documents printed one after another.
+func Concat(docs ...Doc) Doc { return concat(docs) }
+
+// Nest returns doc with the lines inside it indented one level further.
+func Nest(doc Doc) Doc { return nest{doc: doc} }
+
+// Group returns doc as a group, which is printed on one line if it fits.
+func Group(doc Doc) Doc { return group{doc: doc, broken: doc.hard()} }
+
+// IfBreak returns a document that prints broken if the enclosing group is
+// broken across lines, and flat if it is printed on one line.
+func IfBreak(broken, flat Doc) Doc { return ifBreak{broken: broken, flat: flat} }
+
+// cmd is a document waiting to be printed at an indentation level, either
+// flat or broken.
+type cmd struct {
+	depth int
+	flat  bool
+	doc   Doc
+}
+
+// Render prints doc so that its lines fit within width columns where
+// possible, indenting each level with indent.
+func Render(doc Doc, width int, indent string) string {
+	var b strings.Builder
+	col := 0
+	stack := []cmd{{doc: doc}}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := c.doc.(type) {
+		case text:
+			b.WriteString(string(d))
+			if i := strings.LastIndexByte(string(d), '\n'); i >= 0 {
+				col = utf8.RuneCountInString(string(d[i+1:]))
+			} else {
+				col += utf8.RuneCountInString(string(d))
+			}
+		case line:
+			switch {
+			case c.flat && !d.forced && d.soft:
+			case c.flat && !d.forced:
+				b.WriteByte(' ')
+				col++
+			default:
+				b.WriteByte('\n')
+				b.WriteString(strings.Repeat(indent, c.depth))
+				col = utf8.RuneCountInString(indent) * c.depth
+			}
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, cmd{depth: c.depth, flat: c.flat, doc: d[i]})
+			}
+		case nest:
+			stack = append(stack, cmd{depth: c.depth + 1, flat: c.flat, doc: d.doc})
+		case group:
+			flat := c.flat || (!d.broken && fits(cmd{depth: c.depth, flat: true, doc: d.doc}, stack, width-col))
+			stack = append(stack, cmd{depth: c.depth, flat: flat, doc: d.doc})
+		case ifBreak:
+			next := d.broken
+			if c.flat {
+				next = d.flat
+			}
+			stack = append(stack, cmd{depth: c.depth, flat: c.flat, doc: next})
+		}
+	}
+	return b.String()
+}
+
+// fits reports whether next, followed by the rest of the line from the
+// commands in rest, fits within the remaining width.
+func fits(next cmd, rest []cmd, remaining int) bool {
+	cmds := []cmd{next}
+	for remaining >= 0 {
+		if len(cmds) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			cmds = append(cmds, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		c := cmds[len(cmds)-1]
+		cmds = cmds[:len(cmds)-1]
+		switch d := c.doc.(type) {
+		case text:
+			s, _, found := strings.Cut(string(d), "\n")
+			remaining -= utf8.RuneCountInString(s)
+			if found {
+				return remaining >= 0
+			}
+		case line:
+			if !c.flat || d.forced {
+				return true
+			}
+			if !d.soft {
+				remaining--
+			}
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				cmds = append(cmds, cmd{depth: c.depth, flat: c.flat, doc: d[i]})
+			}
+		case nest:
+			cmds = append(cmds, cmd{depth: c.depth + 1, flat: c.flat, doc: d.doc})
+		case group:
+			cmds = append(cmds, cmd{depth: c.depth, flat: c.flat && !d.broken, doc: d.doc})
+		case ifBreak:
+			next := d.broken
+			if c.flat {
+				next = d.flat
+			}
+			cmds = append(cmds, cmd{depth: c.depth, flat: c.flat, doc: next})
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package pretty_test
+
+import (
+	"testing"
+
+	"github.com/KimNorgaard/go-maml/internal/pretty"
+	"github.com/stretchr/testify/require"
+)
+
+// list returns a bracketed, comma-separated group of the items.
+func list(items ...pretty.Doc) pretty.Doc {
+	body := []pretty.Doc{pretty.SoftLine()}
+	for i, item := range items {
+		if i > 0 {
+			body = append(body, pretty.Text(","), pretty.Line())
+		}
+		body = append(body, item)
+	}
+	return pretty.Group(pretty.Concat(
+		pretty.Text("["),
+		pretty.Nest(pretty.Concat(body...)),
+		pretty.IfBreak(pretty.Text(","), pretty.Text("")),
+		pretty.SoftLine(),
+		pretty.Text("]"),
+	))
+}
+
+func words(ws ...string) []pretty.Doc {
+	docs := make([]pretty.Doc, len(ws))
+	for i, w := range ws {
+		docs[i] = pretty.Text(w)
+	}
+	return docs
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   pretty.Doc
+		width int
+		want  string
+	}{
+		{
+			name:  "Fits",
+			doc:   list(words("a", "b", "c")...),
+			width: 9,
+			want:  "[a, b, c]",
+		},
+		{
+			name:  "Too wide",
+			doc:   list(words("a", "b", "c")...),
+			width: 8,
+			want:  "[\n  a,\n  b,\n  c,\n]",
+		},
+		{
+			name:  "Inner group stays flat",
+			doc:   list(list(words("a", "b")...), list(words("c", "d")...)),
+			width: 12,
+			want:  "[\n  [a, b],\n  [c, d],\n]",
+		},
+		{
+			name:  "Trailing text counts",
+			doc:   pretty.Concat(list(words("a", "b")...), pretty.Text(" # long comment")),
+			width: 10,
+			want:  "[\n  a,\n  b,\n] # long comment",
+		},
+		{
+			name:  "Hard line breaks the group",
+			doc:   list(pretty.Text("a"), pretty.Concat(pretty.Text("# b"), pretty.BreakParent())),
+			width: 80,
+			want:  "[\n  a,\n  # b,\n]",
+		},
+		{
+			name: "Multiline text breaks the group",
+			doc: list(pretty.Text("\"\"\"\nx\n\"\"\""), pretty.Concat(
+				pretty.Text("y"), pretty.HardLine(), pretty.Text("z"),
+			)),
+			width: 80,
+			want:  "[\n  \"\"\"\nx\n\"\"\",\n  y\n  z,\n]",
+		},
+		{
+			name:  "Wide characters",
+			doc:   list(words("æøå", "ü")...),
+			width: 10,
+			want:  "[æøå, ü]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, pretty.Render(tt.doc, tt.width, "  "))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package maml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/KimNorgaard/go-maml/ast"
+)
+
+// stringValue returns the value of a string literal, with variable
+// references expanded if the ExpandVariables option is used. Errors are
+// returned as an InterpolationError that points at the string.
+func (ds *decodeState) stringValue(s *ast.StringLiteral) (string, error) {
+	if ds.opts.lookupVariable == nil {
+		return s.Value, nil
+	}
+	v, err := expandVariables(s.Value, ds.opts.lookupVariable)
+	if err != nil {
+		ie := &InterpolationError{Msg: err.Error(), Path: ds.path.String(), Pos: s.Pos(), End: s.End()}
+		var notSet *variableNotSetError
+		if errors.As(err, &notSet) {
+			ie.Variable = notSet.name
+		}
+		return "", ie
+	}
+	return v, nil
+}
+
+// variableNotSetError is returned for a ${NAME} reference to a variable that
+// is unset, or a ${NAME:?message} reference to one that is unset or empty.
+type variableNotSetError struct {
+	name    string
+	message string
+}
+
+func (e *variableNotSetError) Error() string {
+	if e.message == "" {
+		return fmt.Sprintf("variable %s is not set", e.name)
+	}
+	return fmt.Sprintf("variable %s is not set: %s", e.name, e.message)
+}
+
+// expandVariables replaces the variable references in s:
+//
+//	${NAME}            the value of NAME, or an error if it is unset
+//	${NAME:-default}   the value of NAME, or default if it is unset or empty
+//	${NAME:?message}   the value of NAME, or an error if it is unset or empty
+//	$$                 a literal $
+//
+// Defaults and messages may contain references themselves. A $ that is not
+// followed by { or $ is left as is.
+func expandVariables(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q", s[i:])
+			}
+			v, err := expandReference(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// closingBrace returns the index of the } that closes the reference whose
+// body starts at start, or -1 if there is none.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandReference expands the body of a ${...} reference.
+func expandReference(ref string, lookup func(string) (string, bool)) (string, error) {
+	name, op, arg := ref, "", ""
+	if i := strings.IndexByte(ref, ':'); i >= 0 {
+		name, op = ref[:i], ref[i:min(i+2, len(ref))]
+		arg = ref[min(i+2, len(ref)):]
+	}
+	if !isVariableName(name) {
+		return "", fmt.Errorf("invalid variable name %q", name)
+	}
+
+	value, ok := lookup(name)
+	switch op {
+	case "":
+		if !ok {
+			return "", &variableNotSetError{name: name}
+		}
+		return value, nil
+	case ":-":
+		if !ok || value == "" {
+			return expandVariables(arg, lookup)
+		}
+		return value, nil
+	case ":?":
+		if !ok || value == "" {
+			message, err := expandVariables(arg, lookup)
+			if err != nil {
+				return "", err
+			}
+			return "", &variableNotSetError{name: name, message: message}
+		}
+		return value, nil
+	default:
+		return "", fmt.Errorf("invalid variable reference %q", "${"+ref+"}")
+	}
+}
+
+// isVariableName reports whether name is a valid variable name: a letter or
+// underscore followed by letters, digits and underscores.
+func isVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
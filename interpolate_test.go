@@ -0,0 +1,51 @@
+package maml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{"HOST": "db.local", "PORT": "5432", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{input: "plain", expected: "plain"},
+		{input: "${HOST}:${PORT}", expected: "db.local:5432"},
+		{input: "${EMPTY}", expected: ""},
+		{input: "${MISSING}", err: "variable MISSING is not set"},
+		{input: "${MISSING:-localhost}", expected: "localhost"},
+		{input: "${EMPTY:-fallback}", expected: "fallback"},
+		{input: "${HOST:-fallback}", expected: "db.local"},
+		{input: "${MISSING:-${HOST}}", expected: "db.local"},
+		{input: "${HOST:?required}", expected: "db.local"},
+		{input: "costs $5 and $$HOME", expected: "costs $5 and $HOME"},
+		{input: "$${HOST}", expected: "${HOST}"},
+		{input: "trailing $", expected: "trailing $"},
+		{input: "${MISSING:?must be set}", err: "variable MISSING is not set: must be set"},
+		{input: "${EMPTY:?}", err: "variable EMPTY is not set"},
+		{input: "${HOST", err: `unterminated variable reference "${HOST"`},
+		{input: "${1ABC}", err: `invalid variable name "1ABC"`},
+		{input: "${}", err: `invalid variable name ""`},
+		{input: "${HOST:+x}", err: `invalid variable reference "${HOST:+x}"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := expandVariables(tt.input, lookup)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
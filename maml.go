@@ -3,7 +3,7 @@ package maml
 import (
 	"bytes"
 
-	"github.com/KimNorgaard/go-maml/internal/ast"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/KimNorgaard/go-maml/internal/lexer"
 	"github.com/KimNorgaard/go-maml/internal/parser"
 )
@@ -25,11 +25,67 @@ type Unmarshaler interface {
 	UnmarshalMAML([]byte) error
 }
 
+// NodeMarshaler is the interface implemented by types that can marshal
+// themselves into a MAML syntax tree. It is preferred over Marshaler, as the
+// returned node is used as is instead of being parsed from bytes.
+type NodeMarshaler interface {
+	// MarshalMAMLNode returns the MAML value as an AST node. A nil node
+	// encodes as null.
+	MarshalMAMLNode() (ast.Expression, error)
+}
+
+// NodeUnmarshaler is the interface implemented by types that can unmarshal
+// themselves from a MAML syntax tree. It is preferred over Unmarshaler, as
+// the node is passed on directly instead of being formatted and parsed again.
+//
+// The DecodeContext gives access to the decode options and the key path of
+// the value, and can decode nested nodes. It is only valid for the duration
+// of the call.
+type NodeUnmarshaler interface {
+	// UnmarshalMAMLNode unmarshals the node and stores the result in the
+	// value pointed to by the receiver. The node must not be modified.
+	UnmarshalMAMLNode(node ast.Expression, ctx *DecodeContext) error
+}
+
+// Defaulter is the interface implemented by types that set their own default
+// values. The decoder calls SetDefaults on a struct before it populates the
+// fields from an object, and on struct fields that are missing from the
+// document. This includes nested structs, slice elements and map values.
+//
+// Defaults given by struct tags are applied after the fields have been
+// populated, to the fields the document did not set, and so take precedence
+// over the values set by SetDefaults.
+type Defaulter interface {
+	// SetDefaults sets the default values of the receiver.
+	SetDefaults()
+}
+
+// Validator is the interface implemented by types that validate themselves
+// after decoding. The decoder calls ValidateMAML on a struct once its fields
+// have been populated from an object and its defaults applied. A returned
+// error is reported as a ValidationError with the key path and position of
+// the object.
+type Validator interface {
+	// ValidateMAML reports whether the receiver holds a valid value.
+	ValidateMAML() error
+}
+
+// Commenter is the interface implemented by structs that document their
+// fields in the encoded MAML. It takes precedence over the comment and
+// linecomment struct tags.
+type Commenter interface {
+	// CommentMAML returns the comment written above the field with the given
+	// key, which may span several lines, and the comment written after its
+	// value on the same line. Empty strings mean no comment.
+	CommentMAML(key string) (head, line string)
+}
+
 // Marshal returns the MAML encoding of in.
 //
 // Marshal functions similarly to encoding/json.Marshal, traversing the value in
-// recursively. If an encountered value implements the Marshaler interface,
-// Marshal calls its MarshalMAML method to produce MAML.
+// recursively. If an encountered value implements the NodeMarshaler or
+// Marshaler interface, Marshal calls its MarshalMAMLNode or MarshalMAML
+// method to produce MAML.
 //
 // The mapping between Go values and MAML values is analogous to encoding/json:
 //
@@ -39,16 +95,28 @@ type Unmarshaler interface {
 //
 // String values encode as MAML strings.
 //
-// Slices and arrays encode as MAML arrays.
+// Slices and arrays encode as MAML arrays, except that []byte and [N]byte
+// encode as base64 strings. The `hex`, `base64url` and `text` tag options
+// select a different encoding for a field.
 //
 // Struct values encode as MAML objects. Exported fields are used as object keys.
 // The `maml` struct tag can be used to customize key names and behavior,
 // e.g., `maml:"my_key,omitempty"`.
+// The `comment` and `linecomment` tags document a field with a comment above
+// it or after its value, unless the output is compact.
 //
-// Maps encode as MAML objects. The map's key type must be a string.
+// Maps encode as MAML objects. The map's key type must be a string, integer
+// or bool type, or implement encoding.TextMarshaler. Integer keys are written
+// bare and sorted numerically; other keys are sorted as strings. The SortKeys
+// and SortKeysFunc options change the order of struct fields and map keys,
+// and an OrderedMap keeps its keys in the order they were added.
 //
 // Pointers are dereferenced and their values are encoded. A nil pointer
 // encodes as the MAML null value.
+//
+// Values implementing encoding.TextMarshaler encode as MAML strings. A
+// time.Duration encodes as a string such as "1m30s" and a time.Time in RFC
+// 3339 format, or in the layout set with the TimeLayout option.
 func Marshal(in any, opts ...Option) (out []byte, err error) {
 	var buf bytes.Buffer
 	e := NewEncoder(&buf, opts...)
@@ -64,8 +132,17 @@ func Marshal(in any, opts ...Option) (out []byte, err error) {
 //
 // Unmarshal uses a similar mapping from MAML to Go values as encoding/json.Unmarshal,
 // and it will use the inverse of the rules described in Marshal. It supports
-// `maml` struct tags for custom field mapping and honors the Unmarshaler and
-// encoding.TextUnmarshaler interfaces.
+// `maml` struct tags for custom field mapping and honors the NodeUnmarshaler,
+// Unmarshaler and encoding.TextUnmarshaler interfaces, in that order.
+//
+// Objects decoded into an interface value become a map[string]any, or an
+// OrderedMap with the UseOrderedMap option. Decode into a Value to keep the
+// order of keys and the exact numbers of a document whose structure is not
+// known in advance.
+//
+// Numbers decoded into an interface value become an int64, a *big.Int for
+// integers outside the int64 range, or a float64. With the UseNumber option
+// they become a Number instead, which keeps the literal text.
 //
 // If the MAML data contains syntax errors, Unmarshal will return a ParseErrors
 // value containing detailed information about each error.
@@ -79,15 +156,22 @@ func Unmarshal(in []byte, out any, opts ...Option) error {
 // to be programmatically inspected or manipulated while preserving full
 // fidelity, including comments and spacing.
 //
-// The returned ast.Node can then be passed to Marshal to produce formatted
-// MAML output.
+// The node types of the returned document are defined in the public ast
+// package, so the tree can be inspected, edited and extended with nodes built
+// by the ast constructors. The document can then be passed to Marshal to
+// produce formatted MAML output.
+//
+// If the data contains syntax errors, Parse returns a ParseErrors value
+// together with a partial document, in which the invalid parts are replaced
+// by ast.BadExpression nodes. This allows tools such as editors and linters
+// to work with documents that are being edited.
 func Parse(in []byte) (*ast.Document, error) {
 	l := lexer.New(bytes.NewReader(in))
 	// Always parse with comments, as that's the primary use case for this function.
 	p := parser.New(l, parser.WithParseComments())
 	doc := p.Parse()
 	if len(p.Errors()) > 0 {
-		return nil, p.Errors()
+		return doc, p.Errors()
 	}
 	return doc, nil
 }
@@ -2,11 +2,15 @@ package maml_test
 
 import (
 	"errors"
+	"math/big"
+	"net/netip"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
 	"github.com/stretchr/testify/require"
 )
 
@@ -208,6 +212,37 @@ func TestMarshal_CycleDetection(t *testing.T) {
 	})
 }
 
+// Encode returns the errors of the encoder as is, so every error must carry
+// the maml: prefix exactly once.
+func TestMarshal_ErrorPrefix(t *testing.T) {
+	type Node struct {
+		Next *Node
+	}
+	cycle := &Node{}
+	cycle.Next = cycle
+
+	tests := []struct {
+		name  string
+		input any
+	}{
+		{"Unsupported type", make(chan int)},
+		{"Unsupported nested type", map[string]any{"f": func() {}}},
+		{"Cycle", cycle},
+		{"Marshaler error", CustomError{}},
+		{"Marshaler invalid output", CustomInvalidMAML{}},
+		{"Marshaler multiple statements", CustomMultipleStatements{}},
+		{"Infinite big.Float", new(big.Float).SetInf(false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := maml.Marshal(tt.input)
+			require.Error(t, err)
+			require.True(t, strings.HasPrefix(err.Error(), "maml: "), err.Error())
+			require.NotContains(t, err.Error(), "maml: maml:")
+		})
+	}
+}
+
 func TestMarshalUnmarshal_CollectionEdgeCases(t *testing.T) {
 	t.Run("Marshal nil slice", func(t *testing.T) {
 		var s []int // nil slice
@@ -437,6 +472,147 @@ func TestUnmarshal_CustomUnmarshaler(t *testing.T) {
 	})
 }
 
+// NodePoint implements maml.NodeMarshaler and maml.NodeUnmarshaler, encoding
+// as a two-element array.
+type NodePoint struct {
+	X, Y int64
+}
+
+func (p NodePoint) MarshalMAMLNode() (ast.Expression, error) {
+	return ast.NewArray(ast.NewInteger(p.X), ast.NewInteger(p.Y)), nil
+}
+
+func (p *NodePoint) UnmarshalMAMLNode(node ast.Expression, ctx *maml.DecodeContext) error {
+	arr, ok := node.(*ast.ArrayLiteral)
+	if !ok || len(arr.Elements) != 2 {
+		return errors.New("expected [x, y]")
+	}
+	if err := ctx.Decode(arr.Elements[0].Value, &p.X); err != nil {
+		return err
+	}
+	return ctx.Decode(arr.Elements[1].Value, &p.Y)
+}
+
+// NodePreferred implements both the node and the byte based interfaces.
+type NodePreferred struct {
+	Via string
+}
+
+func (p NodePreferred) MarshalMAML() ([]byte, error) { return []byte(`"bytes"`), nil }
+
+func (p NodePreferred) MarshalMAMLNode() (ast.Expression, error) { return ast.NewString("node"), nil }
+
+func (p *NodePreferred) UnmarshalMAML([]byte) error {
+	p.Via = "bytes"
+	return nil
+}
+
+func (p *NodePreferred) UnmarshalMAMLNode(ast.Expression, *maml.DecodeContext) error {
+	p.Via = "node"
+	return nil
+}
+
+// NodeContext records the DecodeContext it is called with.
+type NodeContext struct {
+	Path    string
+	Options int
+}
+
+func (c *NodeContext) UnmarshalMAMLNode(_ ast.Expression, ctx *maml.DecodeContext) error {
+	c.Path = ctx.Path().String()
+	c.Options = len(ctx.Options())
+	return nil
+}
+
+// NodeNil returns a nil node.
+type NodeNil struct{}
+
+func (NodeNil) MarshalMAMLNode() (ast.Expression, error) { return nil, nil }
+
+// NodeError always fails.
+type NodeError struct{}
+
+func (NodeError) MarshalMAMLNode() (ast.Expression, error) { return nil, errors.New("node error") }
+
+func (*NodeError) UnmarshalMAMLNode(ast.Expression, *maml.DecodeContext) error {
+	return errors.New("node error")
+}
+
+func TestNodeMarshaler(t *testing.T) {
+	t.Run("Node is used as is", func(t *testing.T) {
+		b, err := maml.Marshal(struct{ P NodePoint }{NodePoint{1, 2}}, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `{P:[1,2]}`, string(b))
+	})
+
+	t.Run("Preferred over Marshaler", func(t *testing.T) {
+		b, err := maml.Marshal(NodePreferred{})
+		require.NoError(t, err)
+		require.Equal(t, `"node"`, string(b))
+	})
+
+	t.Run("Nil node encodes as null", func(t *testing.T) {
+		b, err := maml.Marshal(NodeNil{})
+		require.NoError(t, err)
+		require.Equal(t, "null", string(b))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := maml.Marshal(NodeError{})
+		var merr *maml.MarshalerError
+		require.ErrorAs(t, err, &merr)
+		require.Contains(t, err.Error(), "node error")
+	})
+}
+
+func TestNodeUnmarshaler(t *testing.T) {
+	t.Run("Node is decoded directly", func(t *testing.T) {
+		var v struct{ P *NodePoint }
+		require.NoError(t, maml.Unmarshal([]byte(`{P: [3, 4]}`), &v))
+		require.Equal(t, &NodePoint{3, 4}, v.P)
+	})
+
+	t.Run("Preferred over Unmarshaler", func(t *testing.T) {
+		var v NodePreferred
+		require.NoError(t, maml.Unmarshal([]byte(`1`), &v))
+		require.Equal(t, "node", v.Via)
+	})
+
+	t.Run("Context carries the path and options", func(t *testing.T) {
+		var v struct {
+			Items []NodeContext `maml:"items"`
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{items: [1, 2]}`), &v, maml.UseNumber()))
+		require.Equal(t, []NodeContext{{Path: "items[0]", Options: 1}, {Path: "items[1]", Options: 1}}, v.Items)
+	})
+
+	t.Run("Errors from Decode keep their position", func(t *testing.T) {
+		var v struct{ P NodePoint }
+		err := maml.Unmarshal([]byte("{\n  P: [1, \"y\"]\n}"), &v)
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "P", typeErr.Path)
+		require.Equal(t, 2, typeErr.Pos.Line)
+		require.Equal(t, 10, typeErr.Pos.Column)
+	})
+
+	t.Run("Decode collects errors with AllErrors", func(t *testing.T) {
+		var v []NodePoint
+		err := maml.Unmarshal([]byte(`[["a", 1], [2, "b"]]`), &v, maml.AllErrors())
+		var errs maml.DecodeErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 2)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var v NodeError
+		err := maml.Unmarshal([]byte(`{}`), &v)
+		var uerr *maml.UnmarshalerError
+		require.ErrorAs(t, err, &uerr)
+		require.Contains(t, err.Error(), "node error")
+	})
+}
+
 func TestParse(t *testing.T) {
 	t.Run("Parse valid MAML", func(t *testing.T) {
 		input := `{ key: "value" }`
@@ -452,6 +628,19 @@ func TestParse(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("Parse invalid MAML returns partial document", func(t *testing.T) {
+		input := "{\n  name: \"demo\"\n  port: 80x\n  debug: true\n}"
+		doc, err := maml.Parse([]byte(input))
+		require.Error(t, err)
+		require.NotNil(t, doc)
+
+		obj, ok := doc.Root().(*ast.ObjectLiteral)
+		require.True(t, ok)
+		require.Len(t, obj.Pairs, 3)
+		require.IsType(t, &ast.BadExpression{}, obj.Pairs[1].Value)
+		require.Equal(t, "debug", obj.Pairs[2].KeyString())
+	})
+
 	t.Run("Parse empty input", func(t *testing.T) {
 		input := ``
 		doc, err := maml.Parse([]byte(input))
@@ -485,4 +674,129 @@ func TestParse(t *testing.T) {
 		require.Contains(t, s, "# Key 1 line comment")
 		require.Contains(t, s, "# Key 2 foot comment")
 	})
+	t.Run("Modify AST through the public ast package", func(t *testing.T) {
+		input := "{\n  # Port to listen on\n  port: 8080\n}"
+		doc, err := maml.Parse([]byte(input))
+		require.NoError(t, err)
+
+		obj, ok := doc.Root().(*ast.ObjectLiteral)
+		require.True(t, ok)
+		obj.Pairs[0].Value = ast.NewInteger(9090)
+		obj.Pairs = append(obj.Pairs, ast.NewKeyValue("host", ast.NewString("localhost")))
+		obj.Pairs[1].HeadComments = []*ast.Comment{ast.NewComment("Interface to bind")}
+
+		output, err := maml.Marshal(doc, maml.Indent(2))
+		require.NoError(t, err)
+		expected := "{\n  # Port to listen on\n  port: 9090\n  # Interface to bind\n  host: \"localhost\"\n}"
+		require.Equal(t, expected, string(output))
+	})
+}
+
+type keyName string
+
+// Level is an enum with a text encoding, used as a map key.
+type Level int
+
+func (l Level) MarshalText() ([]byte, error) {
+	switch l {
+	case 0:
+		return []byte("low"), nil
+	case 1:
+		return []byte("high"), nil
+	}
+	return nil, errors.New("invalid level " + strconv.Itoa(int(l)))
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return errors.New("invalid level " + strconv.Quote(string(text)))
+	}
+	return nil
+}
+
+func TestMapKeys(t *testing.T) {
+	roundTrip := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{
+			name:     "Integer keys are bare and sorted numerically",
+			input:    map[int]string{10: "ten", -2: "minus two", 3: "three"},
+			expected: `{-2:"minus two",3:"three",10:"ten"}`,
+		},
+		{
+			name:     "Unsigned keys",
+			input:    map[uint8]bool{255: true, 0: false},
+			expected: `{0:false,255:true}`,
+		},
+		{
+			name:     "Bool keys",
+			input:    map[bool]int{true: 1, false: 0},
+			expected: `{"false":0,"true":1}`,
+		},
+		{
+			name:     "TextMarshaler keys",
+			input:    map[netip.Addr]string{netip.MustParseAddr("10.0.0.2"): "b", netip.MustParseAddr("10.0.0.1"): "a"},
+			expected: `{"10.0.0.1":"a","10.0.0.2":"b"}`,
+		},
+		{
+			name:     "Enum keys are sorted by their text",
+			input:    map[Level][]string{0: {"debug"}, 1: {"error"}},
+			expected: `{high:["error"],low:["debug"]}`,
+		},
+		{
+			name:     "Named string keys",
+			input:    map[keyName]int{"red": 1},
+			expected: `{red:1}`,
+		},
+	}
+	for _, tt := range roundTrip {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := maml.Marshal(tt.input, maml.Indent(0))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(b))
+
+			out := reflect.New(reflect.TypeOf(tt.input))
+			require.NoError(t, maml.Unmarshal(b, out.Interface()))
+			require.Equal(t, tt.input, out.Elem().Interface())
+		})
+	}
+
+	t.Run("Invalid integer key", func(t *testing.T) {
+		var m map[int8]string
+		err := maml.Unmarshal([]byte(`{1: "a", 300: "b"}`), &m)
+		require.EqualError(t, err, `maml: cannot unmarshal key "300" into Go value of type int8: strconv.ParseInt: parsing "300": value out of range (300 at line 1, column 10)`)
+		var valueErr *maml.InvalidValueError
+		require.ErrorAs(t, err, &valueErr)
+		require.ErrorIs(t, err, strconv.ErrRange)
+		require.Equal(t, `key "300"`, valueErr.Value)
+	})
+
+	t.Run("Invalid TextUnmarshaler key", func(t *testing.T) {
+		var m map[Level]int
+		err := maml.Unmarshal([]byte(`{medium: 1}`), &m)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `invalid level "medium"`)
+	})
+
+	t.Run("TextMarshaler error", func(t *testing.T) {
+		_, err := maml.Marshal(map[Level]int{7: 1})
+		var merr *maml.MarshalerError
+		require.ErrorAs(t, err, &merr)
+	})
+
+	t.Run("Unsupported key types", func(t *testing.T) {
+		_, err := maml.Marshal(map[float64]int{1.5: 1})
+		require.EqualError(t, err, "maml: unsupported map key type float64")
+
+		var m map[[2]int]int
+		err = maml.Unmarshal([]byte(`{}`), &m)
+		require.EqualError(t, err, "maml: cannot unmarshal object into map with key type [2]int")
+	})
 }
@@ -0,0 +1,212 @@
+package maml
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/KimNorgaard/go-maml/ast"
+)
+
+// MarshalInto encodes in and merges the result into doc, changing only what
+// differs between the two. Scalars with a different value are replaced, keys
+// that are missing from doc are inserted and keys that are missing from the
+// encoded value are deleted. Every other pair and array element is kept as it
+// is, including its key as written, its comments, the blank lines before it
+// and its position in the object.
+//
+// The keys of an object encoded from a struct are matched to its fields as
+// Unmarshal matches them, so a key written as "host" updates the field Host.
+// Keys that do not belong to any field of the struct are left alone.
+//
+// Inserted keys are placed after the preceding key of the encoded object, so
+// new struct fields appear next to their neighbours. A value whose kind
+// changed, e.g. from an object to an array, is replaced as a whole.
+//
+// MarshalInto is meant for updating a configuration file that was read with
+// Parse from the Go value it was decoded into:
+//
+//	doc, err := maml.Parse(data)
+//	...
+//	err = maml.Unmarshal(data, &cfg)
+//	...
+//	cfg.Port = 9090
+//	err = maml.MarshalInto(doc, cfg)
+//	...
+//	out, err := maml.Marshal(doc)
+func MarshalInto(doc *ast.Document, in any, opts ...Option) error {
+	if doc == nil {
+		return fmt.Errorf("maml: MarshalInto(nil document)")
+	}
+	o := options{}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return err
+		}
+	}
+
+	es := &encodeState{
+		seen:        make(map[uintptr]struct{}),
+		opts:        &o,
+		structTypes: make(map[*ast.ObjectLiteral]reflect.Type),
+	}
+	node, err := es.marshalValue(reflect.ValueOf(in))
+	if err != nil {
+		return err
+	}
+	expr, ok := node.(ast.Expression)
+	if !ok {
+		return fmt.Errorf("maml: cannot merge %T into a document", node)
+	}
+
+	root := doc.Root()
+	if root == nil {
+		return doc.Set("", expr)
+	}
+	m := merger{structTypes: es.structTypes}
+	if merged := m.mergeNode(root, expr); merged != root {
+		return doc.Set("", merged)
+	}
+	return nil
+}
+
+// A merger merges encoded values into existing ones.
+type merger struct {
+	// The struct types of the encoded objects, used to match keys to fields.
+	structTypes map[*ast.ObjectLiteral]reflect.Type
+}
+
+// mergeNode merges the encoded value b into the existing value a and returns
+// the result. It returns a itself, updated in place, if the two are objects
+// or arrays, or scalars with the same value.
+func (m merger) mergeNode(a, b ast.Expression) ast.Expression {
+	switch a := a.(type) {
+	case *ast.ObjectLiteral:
+		if b, ok := b.(*ast.ObjectLiteral); ok {
+			m.mergeObject(a, b)
+			return a
+		}
+	case *ast.ArrayLiteral:
+		if b, ok := b.(*ast.ArrayLiteral); ok {
+			m.mergeArray(a, b)
+			return a
+		}
+	default:
+		if sameScalar(a, b) {
+			return a
+		}
+	}
+	return b
+}
+
+// encodedKey returns the key of the encoded object b that a key of the
+// existing object corresponds to. The keys of a struct are matched to its
+// fields as the decoder matches them; other keys match exactly. It reports
+// false if key does not belong to any field of the struct.
+func (m merger) encodedKey(b *ast.ObjectLiteral, key string) (string, bool) {
+	t, ok := m.structTypes[b]
+	if !ok {
+		return key, true
+	}
+	f := findField(cachedFields(t), key)
+	if f == nil {
+		return "", false
+	}
+	return f.name, true
+}
+
+// mergeObject updates the pairs of a to match those of b. Pairs are matched
+// by key, with the keys of a struct matched to its fields. The pairs of a keep
+// their order and keys; pairs that are only in b are inserted after the pair
+// that precedes them in b.
+func (m merger) mergeObject(a, b *ast.ObjectLiteral) {
+	wanted := make(map[string]*ast.KeyValueExpression, len(b.Pairs))
+	for _, pair := range b.Pairs {
+		wanted[pair.KeyString()] = pair
+	}
+
+	// Update or remove the existing pairs.
+	pairs := a.Pairs[:0]
+	present := make(map[string]int, len(a.Pairs)) // by key in b
+	for _, pair := range a.Pairs {
+		key, owned := m.encodedKey(b, pair.KeyString())
+		if !owned {
+			pairs = append(pairs, pair)
+			continue
+		}
+		next, ok := wanted[key]
+		if !ok {
+			continue
+		}
+		if _, dup := present[key]; dup {
+			continue
+		}
+		pair.Value = m.mergeNode(pair.Value, next.Value)
+		present[key] = len(pairs)
+		pairs = append(pairs, pair)
+	}
+
+	// Insert the new pairs after their predecessor in b.
+	at := 0
+	for _, pair := range b.Pairs {
+		if i, ok := present[pair.KeyString()]; ok {
+			at = i + 1
+			continue
+		}
+		pairs = append(pairs, nil)
+		copy(pairs[at+1:], pairs[at:])
+		pairs[at] = pair
+		for key, i := range present {
+			if i >= at {
+				present[key] = i + 1
+			}
+		}
+		at++
+	}
+	a.Pairs = pairs
+}
+
+// mergeArray updates the elements of a to match those of b. Elements are
+// matched by index; extra elements of b are appended and extra elements of a
+// are removed.
+func (m merger) mergeArray(a, b *ast.ArrayLiteral) {
+	n := min(len(a.Elements), len(b.Elements))
+	for i, el := range a.Elements[:n] {
+		el.Value = m.mergeNode(el.Value, b.Elements[i].Value)
+	}
+	a.Elements = append(a.Elements[:n], b.Elements[n:]...)
+}
+
+// sameScalar reports whether a and b are scalars with the same value. An
+// integer and a float are the same if they are numerically equal, so that a
+// float written as 1 in the document is not rewritten as 1.0.
+func sameScalar(a, b ast.Expression) bool {
+	switch a := a.(type) {
+	case *ast.StringLiteral:
+		b, ok := b.(*ast.StringLiteral)
+		return ok && a.Value == b.Value
+	case *ast.Identifier:
+		b, ok := b.(*ast.Identifier)
+		return ok && a.Value == b.Value
+	case *ast.BooleanLiteral:
+		b, ok := b.(*ast.BooleanLiteral)
+		return ok && a.Value == b.Value
+	case *ast.NullLiteral:
+		_, ok := b.(*ast.NullLiteral)
+		return ok
+	case *ast.IntegerLiteral:
+		switch b := b.(type) {
+		case *ast.IntegerLiteral:
+			return a.BigInt().Cmp(b.BigInt()) == 0
+		case *ast.FloatLiteral:
+			return a.Big == nil && float64(a.Value) == b.Value
+		}
+	case *ast.FloatLiteral:
+		switch b := b.(type) {
+		case *ast.FloatLiteral:
+			return a.Value == b.Value
+		case *ast.IntegerLiteral:
+			return b.Big == nil && a.Value == float64(b.Value)
+		}
+	}
+	return false
+}
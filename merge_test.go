@@ -0,0 +1,287 @@
+package maml_test
+
+import (
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/stretchr/testify/require"
+)
+
+type mergeServer struct {
+	Host    string   `maml:"host"`
+	Port    int      `maml:"port"`
+	Timeout float64  `maml:"timeout"`
+	TLS     bool     `maml:"tls"`
+	Tags    []string `maml:"tags"`
+}
+
+type mergeConfig struct {
+	Name   string            `maml:"name"`
+	Server mergeServer       `maml:"server"`
+	Labels map[string]string `maml:"labels,omitempty"`
+}
+
+const mergeSource = `# Service configuration
+{
+  name: "api"
+
+  # Where to listen
+  server: {
+    host: "localhost" # local only
+    port: 8080
+    timeout: 30.0
+    tls: false
+
+    # Extra tags
+    tags: [
+      "a"
+      "b"
+    ]
+  }
+}`
+
+func TestMarshalInto(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(*mergeConfig)
+		want   string
+	}{
+		{
+			name:   "Unchanged",
+			update: func(*mergeConfig) {},
+			want:   mergeSource,
+		},
+		{
+			name:   "Changed scalar keeps comments",
+			update: func(c *mergeConfig) { c.Server.Host = "0.0.0.0" },
+			want: `# Service configuration
+{
+  name: "api"
+
+  # Where to listen
+  server: {
+    host: "0.0.0.0" # local only
+    port: 8080
+    timeout: 30.0
+    tls: false
+
+    # Extra tags
+    tags: [
+      "a"
+      "b"
+    ]
+  }
+}`,
+		},
+		{
+			name: "Changed number",
+			update: func(c *mergeConfig) {
+				c.Server.Port = 9090
+				c.Server.Timeout = 2.5
+			},
+			want: `# Service configuration
+{
+  name: "api"
+
+  # Where to listen
+  server: {
+    host: "localhost" # local only
+    port: 9090
+    timeout: 2.5
+    tls: false
+
+    # Extra tags
+    tags: [
+      "a"
+      "b"
+    ]
+  }
+}`,
+		},
+		{
+			name: "Array elements",
+			update: func(c *mergeConfig) {
+				c.Server.Tags = []string{"a", "c", "d"}
+			},
+			want: `# Service configuration
+{
+  name: "api"
+
+  # Where to listen
+  server: {
+    host: "localhost" # local only
+    port: 8080
+    timeout: 30.0
+    tls: false
+
+    # Extra tags
+    tags: [
+      "a"
+      "c"
+      "d"
+    ]
+  }
+}`,
+		},
+		{
+			name: "Inserted key and null value",
+			update: func(c *mergeConfig) {
+				c.Server.TLS = true
+				c.Server.Tags = nil
+				c.Labels = map[string]string{"team": "core"}
+			},
+			want: `# Service configuration
+{
+  name: "api"
+
+  # Where to listen
+  server: {
+    host: "localhost" # local only
+    port: 8080
+    timeout: 30.0
+    tls: true
+
+    # Extra tags
+    tags: null
+  }
+  labels: {
+    team: "core"
+  }
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := maml.Parse([]byte(mergeSource))
+			require.NoError(t, err)
+			var cfg mergeConfig
+			require.NoError(t, maml.Unmarshal([]byte(mergeSource), &cfg))
+
+			tt.update(&cfg)
+			require.NoError(t, maml.MarshalInto(doc, cfg))
+
+			out, err := maml.Marshal(doc)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(out))
+		})
+	}
+}
+
+func TestMarshalInto_InsertDelete(t *testing.T) {
+	doc, err := maml.Parse([]byte(`{
+  # first
+  a: 1
+
+  # second
+  b: 2
+
+  # third
+  c: 3
+}
+`))
+	require.NoError(t, err)
+
+	require.NoError(t, maml.MarshalInto(doc, map[string]any{"a": 1, "ab": 0, "c": 4, "d": 5}))
+	out, err := maml.Marshal(doc)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  # first
+  a: 1
+  ab: 0
+
+  # third
+  c: 4
+  d: 5
+}`, string(out))
+}
+
+func TestMarshalInto_FieldNames(t *testing.T) {
+	type Server struct {
+		Host    string
+		Port    int
+		Comment string `maml:",omitempty"`
+	}
+	type Config struct {
+		Server Server
+	}
+	src := `{
+  # The server
+  server: {
+    host: "localhost" # local only
+    PORT: 8080
+    comment: "drop me"
+
+    # Not a field of Server
+    weight: 3
+  }
+  extra: true
+}`
+	doc, err := maml.Parse([]byte(src))
+	require.NoError(t, err)
+	var cfg Config
+	require.NoError(t, maml.Unmarshal([]byte(src), &cfg))
+	require.Equal(t, "localhost", cfg.Server.Host)
+
+	cfg.Server.Port = 9090
+	cfg.Server.Comment = ""
+	require.NoError(t, maml.MarshalInto(doc, cfg))
+	out, err := maml.Marshal(doc)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  # The server
+  server: {
+    host: "localhost" # local only
+    PORT: 9090
+
+    # Not a field of Server
+    weight: 3
+  }
+  extra: true
+}`, string(out))
+
+	t.Run("Map keys match exactly", func(t *testing.T) {
+		doc, err := maml.Parse([]byte(`{Host: "a", host: "b"}`))
+		require.NoError(t, err)
+		require.NoError(t, maml.MarshalInto(doc, map[string]string{"host": "c"}))
+		out, err := maml.Marshal(doc, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `{host:"c"}`, string(out))
+	})
+}
+
+func TestMarshalInto_Replace(t *testing.T) {
+	t.Run("Kind changed", func(t *testing.T) {
+		doc, err := maml.Parse([]byte("{\n  # value\n  v: {a: 1}\n}\n"))
+		require.NoError(t, err)
+		require.NoError(t, maml.MarshalInto(doc, map[string]any{"v": []int{1}}))
+		out, err := maml.Marshal(doc, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `{v:[1]}`, string(out))
+		require.Len(t, doc.Root().(*ast.ObjectLiteral).Pairs[0].HeadComments, 1)
+	})
+
+	t.Run("Empty document", func(t *testing.T) {
+		doc := ast.NewDocument(nil)
+		require.NoError(t, maml.MarshalInto(doc, []int{1, 2}))
+		out, err := maml.Marshal(doc, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `[1,2]`, string(out))
+	})
+
+	t.Run("Root scalar keeps document comments", func(t *testing.T) {
+		doc, err := maml.Parse([]byte("# answer\n41\n"))
+		require.NoError(t, err)
+		require.NoError(t, maml.MarshalInto(doc, 42))
+		out, err := maml.Marshal(doc)
+		require.NoError(t, err)
+		require.Equal(t, "# answer\n42", string(out))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		require.EqualError(t, maml.MarshalInto(nil, 1), "maml: MarshalInto(nil document)")
+		doc := ast.NewDocument(nil)
+		require.Error(t, maml.MarshalInto(doc, make(chan int)))
+	})
+}
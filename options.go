@@ -17,6 +17,35 @@ type options struct {
 	// return an error when encountering unknown fields in the MAML document.
 	disallowUnknownFields bool
 
+	// allErrors specifies whether the decoder should continue after a
+	// value fails to decode and report all such errors at once.
+	allErrors bool
+
+	// useNumber specifies whether the decoder should decode numbers into
+	// interface values as a Number instead of an int64 or float64.
+	useNumber bool
+
+	// lookupVariable specifies the function used to look up the variables
+	// referenced in string values. If nil, references are not expanded.
+	lookupVariable func(name string) (string, bool)
+
+	// timeLayout specifies the layout used to encode and decode time.Time
+	// values. If empty, RFC 3339 is used.
+	timeLayout string
+
+	// keyOrder specifies the order in which the encoder writes the keys of
+	// structs and maps. compareKeys is the comparator of orderCustom.
+	keyOrder    keyOrder
+	compareKeys func(a, b string) int
+
+	// useOrderedMap specifies whether the decoder should decode objects into
+	// interface values as an OrderedMap instead of a map[string]any.
+	useOrderedMap bool
+
+	// maxLineWidth specifies the width within which the encoder keeps short
+	// arrays and objects on a single line. If 0, they are always expanded.
+	maxLineWidth int
+
 	// inlineArrays specifies whether the encoder should format arrays on a
 	// single line.
 	inlineArrays bool
@@ -67,6 +96,121 @@ func DisallowUnknownFields() Option {
 	}
 }
 
+// AllErrors returns an Option that causes the decoder to keep going when a
+// value cannot be decoded, instead of stopping at the first failure. Type
+// mismatches, numeric overflows, unknown fields (with DisallowUnknownFields),
+// missing required fields and validation errors are collected and returned
+// together as a DecodeErrors value once the whole document has been
+// processed. Values that fail to decode are skipped.
+func AllErrors() Option {
+	return func(o *options) error {
+		o.allErrors = true
+		return nil
+	}
+}
+
+// UseNumber returns an Option that causes the decoder to decode numbers into
+// interface values as a Number, keeping the literal text, instead of as an
+// int64, *big.Int or float64.
+func UseNumber() Option {
+	return func(o *options) error {
+		o.useNumber = true
+		return nil
+	}
+}
+
+// UseOrderedMap returns an Option that causes the decoder to decode objects
+// into interface values as an OrderedMap, keeping the keys in source order,
+// instead of as a map[string]any.
+func UseOrderedMap() Option {
+	return func(o *options) error {
+		o.useOrderedMap = true
+		return nil
+	}
+}
+
+// ExpandVariables returns an Option that causes the decoder to expand
+// variable references in string values, using lookup to find the value of a
+// variable. Pass os.LookupEnv to expand environment variables.
+//
+// The references ${NAME}, ${NAME:-default} and ${NAME:?message} are supported,
+// with the same meaning as in a POSIX shell with set -u, and $$ is replaced by
+// a single $. A reference to an unset variable without a default, an unset or
+// empty variable with the :? form, or a malformed reference, is reported as
+// an InterpolationError at the position of the string.
+func ExpandVariables(lookup func(name string) (string, bool)) Option {
+	return func(o *options) error {
+		if lookup == nil {
+			return fmt.Errorf("maml: lookup function must not be nil")
+		}
+		o.lookupVariable = lookup
+		return nil
+	}
+}
+
+// TimeLayout returns an Option that sets the layout, as understood by
+// time.Parse and time.Time.Format, that is used to encode and decode
+// time.Time values. By default times are written in RFC 3339 format with
+// nanoseconds, and read in RFC 3339 format.
+func TimeLayout(layout string) Option {
+	return func(o *options) error {
+		if layout == "" {
+			return fmt.Errorf("maml: time layout must not be empty")
+		}
+		o.timeLayout = layout
+		return nil
+	}
+}
+
+// keyOrder is the order of the keys of encoded structs and maps.
+type keyOrder int
+
+const (
+	orderDeclaration keyOrder = iota // struct fields as declared, map keys sorted
+	orderSorted                      // struct fields and map keys sorted
+	orderCustom                      // struct fields and map keys sorted by compareKeys
+)
+
+// DeclarationOrder returns an Option that causes the encoder to write struct
+// fields in the order they are declared and map keys in sorted order. This is
+// the default.
+func DeclarationOrder() Option {
+	return func(o *options) error {
+		o.keyOrder = orderDeclaration
+		o.compareKeys = nil
+		return nil
+	}
+}
+
+// SortKeys returns an Option that causes the encoder to write the keys of
+// structs, as well as maps, in sorted order. Integer map keys are sorted
+// numerically and all other keys alphabetically, including integer keys
+// that are encoded with encoding.TextMarshaler.
+func SortKeys() Option {
+	return func(o *options) error {
+		o.keyOrder = orderSorted
+		o.compareKeys = nil
+		return nil
+	}
+}
+
+// SortKeysFunc returns an Option that causes the encoder to sort the keys of
+// structs and maps with cmp, which compares two keys as written in the output
+// and returns a negative number, zero or a positive number, like
+// strings.Compare. Keys that compare equal keep their default order.
+//
+// The keys of an OrderedMap are always written in insertion order.
+func SortKeysFunc(cmp func(a, b string) int) Option {
+	return func(o *options) error {
+		if cmp == nil {
+			return fmt.Errorf("maml: key comparator must not be nil")
+		}
+		o.keyOrder = orderCustom
+		o.compareKeys = cmp
+		return nil
+	}
+}
+
 // Indent returns an Option that sets the indentation for the encoder.
 // It specifies the number of spaces to use for each level of indentation.
 //
@@ -82,8 +226,27 @@ func Indent(n int) Option {
 	}
 }
 
+// MaxLineWidth returns an Option that causes the encoder to write arrays and
+// objects on a single line when they fit within n columns, and to break them
+// across lines otherwise, each nested value being laid out again on its own.
+// The root object is always expanded, as are values with comments inside.
+// Without this option, arrays and objects are always expanded.
+//
+// Single-line arrays are written as [1, 2, 3] and objects as { a: 1, b: 2 }.
+// The option has no effect on compact output.
+func MaxLineWidth(n int) Option {
+	return func(o *options) error {
+		if n <= 0 {
+			return fmt.Errorf("maml: max line width must be a positive integer")
+		}
+		o.maxLineWidth = n
+		return nil
+	}
+}
+
 // InlineArrays returns an Option that causes the encoder to
-// inline arrays in the output.
+// inline arrays in the output. Arrays with comments on their elements are
+// still written one element per line so the comments are kept.
 func InlineArrays() Option {
 	return func(o *options) error {
 		o.inlineArrays = true
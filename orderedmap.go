@@ -0,0 +1,123 @@
+package maml
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/KimNorgaard/go-maml/ast"
+)
+
+// OrderedMap is a MAML object that keeps the order of its keys. It decodes
+// from an object with the keys in source order and encodes with the keys in
+// the order of the slice, regardless of the key order options, so that a
+// document read into an OrderedMap can be written back without reordering
+// its keys.
+//
+// Objects nested in the values of an OrderedMap decode as OrderedMap values
+// too. Use the UseOrderedMap option to decode every object in an interface
+// value as an OrderedMap.
+type OrderedMap []MapItem
+
+// MapItem is a key and its value in an OrderedMap.
+type MapItem struct {
+	Key   string
+	Value any
+}
+
+// Get returns the value of key and whether the map contains it.
+func (m OrderedMap) Get(key string) (any, bool) {
+	if i := m.index(key); i >= 0 {
+		return m[i].Value, true
+	}
+	return nil, false
+}
+
+// Keys returns the keys of the map in order.
+func (m OrderedMap) Keys() []string {
+	keys := make([]string, len(m))
+	for i, item := range m {
+		keys[i] = item.Key
+	}
+	return keys
+}
+
+// Set sets the value of key. A new key is appended to the end of the map; an
+// existing key keeps its position.
+func (m *OrderedMap) Set(key string, value any) {
+	if i := m.index(key); i >= 0 {
+		(*m)[i].Value = value
+		return
+	}
+	*m = append(*m, MapItem{Key: key, Value: value})
+}
+
+// Delete removes key from the map.
+func (m *OrderedMap) Delete(key string) {
+	if i := m.index(key); i >= 0 {
+		*m = append((*m)[:i], (*m)[i+1:]...)
+	}
+}
+
+// index returns the position of key in the map, or -1.
+func (m OrderedMap) index(key string) int {
+	for i, item := range m {
+		if item.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+var orderedMapType = reflect.TypeFor[OrderedMap]()
+
+// mapOrderedMap decodes an object into an OrderedMap. Objects in the values
+// are decoded as OrderedMap values as well.
+func (ds *decodeState) mapOrderedMap(obj *ast.ObjectLiteral, rv reflect.Value) error {
+	m := make(OrderedMap, 0, len(obj.Pairs))
+	outer := ds.ordered
+	ds.ordered = true
+	defer func() { ds.ordered = outer }()
+	for _, pair := range obj.Pairs {
+		key, err := resolveMapKey(pair.Key)
+		if err != nil {
+			return err
+		}
+		var value any
+		ds.pushKey(key)
+		err = ds.mapValue(pair.Value, reflect.ValueOf(&value).Elem())
+		ds.pop()
+		if err != nil {
+			return err
+		}
+		m = append(m, MapItem{Key: key, Value: value})
+	}
+	rv.Set(reflect.ValueOf(m))
+	return nil
+}
+
+// marshalOrderedMap encodes an OrderedMap as an object with the keys in the
+// order of the map.
+func (e *encodeState) marshalOrderedMap(v reflect.Value) (ast.Node, error) {
+	if v.IsNil() {
+		return ast.NewNull(), nil
+	}
+	seen := make(map[string]struct{}, v.Len())
+	pairs := make([]*ast.KeyValueExpression, 0, v.Len())
+	for i := range v.Len() {
+		key := v.Index(i).Field(0).String()
+		if _, dup := seen[key]; dup {
+			return nil, fmt.Errorf("maml: duplicate key %q in OrderedMap", key)
+		}
+		seen[key] = struct{}{}
+		valueNode, err := e.marshalValue(v.Index(i).Field(1))
+		if err != nil {
+			return nil, err
+		}
+		valueExpr, ok := valueNode.(ast.Expression)
+		if !ok {
+			return nil, fmt.Errorf("maml: marshaled map value is not an expression")
+		}
+		pairs = append(pairs, ast.NewKeyValue(key, valueExpr))
+	}
+	return ast.NewObject(pairs...), nil
+}
@@ -0,0 +1,99 @@
+package maml_test
+
+import (
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMap(t *testing.T) {
+	src := `{
+  zone: "eu"
+  name: "api"
+  limits: {
+    memory: "1Gi"
+    cpu: 2
+  }
+  ports: [
+    {
+      target: 80
+      name: "http"
+    }
+  ]
+}`
+
+	var m maml.OrderedMap
+	require.NoError(t, maml.Unmarshal([]byte(src), &m))
+	require.Equal(t, []string{"zone", "name", "limits", "ports"}, m.Keys())
+
+	limits, ok := m.Get("limits")
+	require.True(t, ok)
+	require.Equal(t, maml.OrderedMap{{Key: "memory", Value: "1Gi"}, {Key: "cpu", Value: int64(2)}}, limits)
+	ports, _ := m.Get("ports")
+	require.Equal(t, []any{maml.OrderedMap{{Key: "target", Value: int64(80)}, {Key: "name", Value: "http"}}}, ports)
+
+	out, err := maml.Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, src, string(out))
+
+	out, err = maml.Marshal(m, maml.SortKeys())
+	require.NoError(t, err)
+	require.Equal(t, src, string(out), "an OrderedMap ignores the key order options")
+}
+
+func TestOrderedMap_Methods(t *testing.T) {
+	var m maml.OrderedMap
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("b", 3)
+	require.Equal(t, maml.OrderedMap{{Key: "b", Value: 3}, {Key: "a", Value: 2}}, m)
+
+	_, ok := m.Get("c")
+	require.False(t, ok)
+
+	m.Delete("b")
+	m.Delete("c")
+	require.Equal(t, []string{"a"}, m.Keys())
+}
+
+func TestOrderedMap_Decode(t *testing.T) {
+	t.Run("UseOrderedMap", func(t *testing.T) {
+		var v any
+		require.NoError(t, maml.Unmarshal([]byte(`{b: {d: 1, c: 2}, a: [{y: 1, x: 2}]}`), &v, maml.UseOrderedMap()))
+		require.Equal(t, maml.OrderedMap{
+			{Key: "b", Value: maml.OrderedMap{{Key: "d", Value: int64(1)}, {Key: "c", Value: int64(2)}}},
+			{Key: "a", Value: []any{maml.OrderedMap{{Key: "y", Value: int64(1)}, {Key: "x", Value: int64(2)}}}},
+		}, v)
+	})
+
+	t.Run("Struct field", func(t *testing.T) {
+		var v struct {
+			Env  maml.OrderedMap  `maml:"env"`
+			Opts *maml.OrderedMap `maml:"opts"`
+		}
+		require.NoError(t, maml.Unmarshal([]byte(`{env: {PATH: "/bin", HOME: "/root"}, opts: null}`), &v))
+		require.Equal(t, []string{"PATH", "HOME"}, v.Env.Keys())
+		require.Nil(t, v.Opts)
+	})
+
+	t.Run("Not an object", func(t *testing.T) {
+		var m maml.OrderedMap
+		err := maml.Unmarshal([]byte(`[1, 2]`), &m)
+		var typeErr *maml.UnmarshalTypeError
+		require.ErrorAs(t, err, &typeErr)
+		require.Equal(t, "array", typeErr.Value)
+	})
+}
+
+func TestOrderedMap_Encode(t *testing.T) {
+	out, err := maml.Marshal(map[string]any{
+		"env": maml.OrderedMap{{Key: "Z", Value: 1}, {Key: "A", Value: nil}},
+		"nil": maml.OrderedMap(nil),
+	}, maml.Indent(0))
+	require.NoError(t, err)
+	require.Equal(t, `{env:{Z:1,A:null},nil:null}`, string(out))
+
+	_, err = maml.Marshal(maml.OrderedMap{{Key: "a", Value: 1}, {Key: "a", Value: 2}})
+	require.EqualError(t, err, `maml: duplicate key "a" in OrderedMap`)
+}
@@ -0,0 +1,103 @@
+package maml
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/KimNorgaard/go-maml/ast"
+)
+
+// Standard library types with a built-in MAML encoding. They take precedence
+// over any Marshaler or encoding.TextMarshaler implementation of the type.
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	timeType     = reflect.TypeFor[time.Time]()
+	urlType      = reflect.TypeFor[url.URL]()
+)
+
+// isStdType reports whether t has a built-in encoding.
+func isStdType(t reflect.Type) bool {
+	switch t {
+	case bigIntType, bigFloatType, durationType, timeType, urlType:
+		return true
+	}
+	return false
+}
+
+// marshalStdType encodes a value of a type for which isStdType is true.
+// Durations are written like "1h30m", times in the layout set with the
+// TimeLayout option and URLs in their string form.
+func (e *encodeState) marshalStdType(v reflect.Value) (ast.Node, error) {
+	switch v.Type() {
+	case bigIntType:
+		return e.marshalBigInt(v)
+	case bigFloatType:
+		return e.marshalBigFloat(v)
+	case durationType:
+		return ast.NewString(time.Duration(v.Int()).String()), nil
+	case timeType:
+		t, _ := v.Interface().(time.Time)
+		layout := e.opts.timeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return ast.NewString(t.Format(layout)), nil
+	case urlType:
+		u, _ := v.Interface().(url.URL)
+		return ast.NewString(u.String()), nil
+	default:
+		return nil, fmt.Errorf("maml: unsupported type for marshaling: %s", v.Type())
+	}
+}
+
+// mapStdType decodes a string into a time.Duration, time.Time or url.URL.
+// It reports whether the string was handled. Other values are left to the
+// default decoding, so that an integer still decodes into a time.Duration
+// as a number of nanoseconds.
+func (ds *decodeState) mapStdType(expr ast.Expression, rv reflect.Value) (bool, error) {
+	s, ok := expr.(*ast.StringLiteral)
+	if !ok || !rv.CanSet() {
+		return false, nil
+	}
+	if t := rv.Type(); t != durationType && t != timeType && t != urlType {
+		return false, nil
+	}
+	text, err := ds.stringValue(s)
+	if err != nil {
+		return true, ds.report(err)
+	}
+	switch rv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return true, ds.parseError("string", s, rv.Type(), err)
+		}
+		rv.SetInt(int64(d))
+	case timeType:
+		layout := ds.opts.timeLayout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, text)
+		if err != nil {
+			return true, ds.parseError("string", s, rv.Type(), err)
+		}
+		rv.Set(reflect.ValueOf(t))
+	case urlType:
+		u, err := url.Parse(text)
+		if err != nil {
+			return true, ds.parseError("string", s, rv.Type(), err)
+		}
+		rv.Set(reflect.ValueOf(*u))
+	}
+	return true, nil
+}
+
+// parseError reports an InvalidValueError for a string that is not valid for
+// a Go value of type t. The kind describes the string in the message, e.g.
+// "string" or `key "x"`.
+func (ds *decodeState) parseError(kind string, expr ast.Expression, t reflect.Type, err error) error {
+	return ds.report(&InvalidValueError{Value: kind, Type: t, Path: ds.path.String(), Pos: expr.Pos(), End: expr.End(), Err: err})
+}
@@ -0,0 +1,128 @@
+package maml_test
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/stretchr/testify/require"
+)
+
+type stdTypes struct {
+	Timeout  time.Duration    `maml:"timeout"`
+	Created  time.Time        `maml:"created"`
+	Expires  *time.Time       `maml:"expires"`
+	Addr     netip.Addr       `maml:"addr"`
+	Prefix   netip.Prefix     `maml:"prefix"`
+	Endpoint url.URL          `maml:"endpoint"`
+	Proxy    *url.URL         `maml:"proxy"`
+	Pattern  *regexp.Regexp   `maml:"pattern"`
+	Mirrors  []netip.AddrPort `maml:"mirrors"`
+}
+
+func TestStdTypes(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 500, time.UTC)
+	expires := time.Date(2025, 1, 1, 0, 0, 0, 0, time.FixedZone("", 2*60*60))
+	in := stdTypes{
+		Timeout:  90 * time.Second,
+		Created:  created,
+		Expires:  &expires,
+		Addr:     netip.MustParseAddr("192.168.0.1"),
+		Prefix:   netip.MustParsePrefix("10.0.0.0/8"),
+		Endpoint: url.URL{Scheme: "https", Host: "example.com", Path: "/api"},
+		Proxy:    &url.URL{Scheme: "http", Host: "proxy:3128"},
+		Pattern:  regexp.MustCompile(`^v\d+$`),
+		Mirrors:  []netip.AddrPort{netip.MustParseAddrPort("[::1]:8080")},
+	}
+
+	b, err := maml.Marshal(in, maml.Indent(0))
+	require.NoError(t, err)
+	require.Equal(t, `{timeout:"1m30s",created:"2024-05-01T12:30:00.0000005Z",expires:"2025-01-01T00:00:00+02:00",`+
+		`addr:"192.168.0.1",prefix:"10.0.0.0/8",endpoint:"https://example.com/api",proxy:"http://proxy:3128",`+
+		`pattern:"^v\\d+$",mirrors:["[::1]:8080"]}`, string(b))
+
+	var out stdTypes
+	require.NoError(t, maml.Unmarshal(b, &out))
+	require.Equal(t, in.Timeout, out.Timeout)
+	require.True(t, in.Created.Equal(out.Created))
+	require.True(t, in.Expires.Equal(*out.Expires))
+	require.Equal(t, in.Addr, out.Addr)
+	require.Equal(t, in.Prefix, out.Prefix)
+	require.Equal(t, in.Endpoint, out.Endpoint)
+	require.Equal(t, in.Proxy, out.Proxy)
+	require.Equal(t, in.Pattern.String(), out.Pattern.String())
+	require.Equal(t, in.Mirrors, out.Mirrors)
+}
+
+func TestStdTypes_Duration(t *testing.T) {
+	var v struct{ D time.Duration }
+	require.NoError(t, maml.Unmarshal([]byte(`{D: 1500}`), &v))
+	require.Equal(t, 1500*time.Nanosecond, v.D, "integers are nanoseconds")
+
+	err := maml.Unmarshal([]byte(`{D: "soon"}`), &v)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `maml: cannot unmarshal string into Go value of type time.Duration: time: invalid duration "soon" (D at line 1, column 5)`)
+	var valueErr *maml.InvalidValueError
+	require.ErrorAs(t, err, &valueErr)
+	require.Equal(t, "D", valueErr.Path)
+	start, end := valueErr.Span()
+	require.Equal(t, 5, start.Column)
+	require.Equal(t, 11, end.Column)
+}
+
+func TestStdTypes_TimeLayout(t *testing.T) {
+	type event struct {
+		Day time.Time `maml:"day"`
+	}
+	in := event{Day: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)}
+
+	b, err := maml.Marshal(in, maml.Indent(0), maml.TimeLayout(time.DateOnly))
+	require.NoError(t, err)
+	require.Equal(t, `{day:"2024-02-29"}`, string(b))
+
+	var out event
+	require.NoError(t, maml.Unmarshal(b, &out, maml.TimeLayout(time.DateOnly)))
+	require.Equal(t, in, out)
+
+	err = maml.Unmarshal(b, &out)
+	require.Error(t, err, "the default layout is RFC 3339")
+	require.Contains(t, err.Error(), "time.Time")
+
+	_, err = maml.Marshal(in, maml.TimeLayout(""))
+	require.EqualError(t, err, "maml: time layout must not be empty")
+}
+
+type badText struct{}
+
+func (badText) MarshalText() ([]byte, error) { return nil, errors.New("bad text") }
+
+func TestStdTypes_TextMarshaler(t *testing.T) {
+	t.Run("Value receiver", func(t *testing.T) {
+		b, err := maml.Marshal(map[string]any{"ip": netip.MustParseAddr("::1")}, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `{ip:"::1"}`, string(b))
+	})
+
+	t.Run("Big numbers stay numbers", func(t *testing.T) {
+		b, err := maml.Marshal([]any{big.NewInt(7), *big.NewFloat(1.5)}, maml.Indent(0))
+		require.NoError(t, err)
+		require.Equal(t, `[7,1.5]`, string(b))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := maml.Marshal(badText{})
+		var merr *maml.MarshalerError
+		require.ErrorAs(t, err, &merr)
+		require.Contains(t, err.Error(), "bad text")
+
+		var v struct{ Pattern *regexp.Regexp }
+		err = maml.Unmarshal([]byte(`{Pattern: "("}`), &v)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "missing closing )")
+	})
+}
@@ -1,14 +1,45 @@
+// Package token defines the lexical tokens of MAML and their source positions.
 package token
 
+import "strconv"
+
 // Type is the type of a token.
 type Type string
 
+// Position describes a location in the source. Line and Column are 1-based,
+// with columns counted in runes. Offset is the 0-based byte offset.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position refers to a location in the source.
+// Nodes built programmatically have no position.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position in the form "line:column", or "-" if the
+// position is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
 // Token represents a lexical token.
 type Token struct {
 	Type    Type
 	Literal string
 	Line    int
 	Column  int
+	Offset  int      // byte offset of the first character of the token
+	End     Position // position immediately after the last character of the token
+}
+
+// Pos returns the position of the first character of the token.
+func (t Token) Pos() Position {
+	return Position{Offset: t.Offset, Line: t.Line, Column: t.Column}
 }
 
 const (
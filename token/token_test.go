@@ -26,3 +26,14 @@ func TestLookupIdent(t *testing.T) {
 		})
 	}
 }
+
+func TestPosition(t *testing.T) {
+	tok := Token{Type: IDENT, Literal: "key", Line: 2, Column: 3, Offset: 10, End: Position{Offset: 13, Line: 2, Column: 6}}
+	require.Equal(t, Position{Offset: 10, Line: 2, Column: 3}, tok.Pos())
+	require.Equal(t, "2:3", tok.Pos().String())
+	require.True(t, tok.End.IsValid())
+
+	var zero Position
+	require.False(t, zero.IsValid())
+	require.Equal(t, "-", zero.String())
+}
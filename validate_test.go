@@ -0,0 +1,140 @@
+package maml_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/stretchr/testify/require"
+)
+
+type requiredServer struct {
+	Host string `maml:"host,required"`
+	Port int    `maml:"port,required"`
+	Name string `maml:"name"`
+}
+
+func TestRequiredFields(t *testing.T) {
+	t.Run("Present", func(t *testing.T) {
+		var v requiredServer
+		require.NoError(t, maml.Unmarshal([]byte(`{host: "a", port: 0}`), &v))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		var v struct {
+			Servers []requiredServer `maml:"servers"`
+		}
+		err := maml.Unmarshal([]byte("{\n  servers: [\n    {host: \"a\"}\n  ]\n}"), &v)
+		var missing *maml.MissingFieldError
+		require.ErrorAs(t, err, &missing)
+		require.Equal(t, "port", missing.Field)
+		require.Equal(t, "servers[0]", missing.Path)
+		require.Equal(t, 3, missing.Pos.Line)
+		require.Equal(t, 5, missing.Pos.Column)
+		require.EqualError(t, err, `maml: missing required field "port" in type maml_test.requiredServer (servers[0] at line 3, column 5)`)
+	})
+
+	t.Run("Missing nested struct", func(t *testing.T) {
+		var v struct {
+			Server requiredServer  `maml:"server"`
+			Backup *requiredServer `maml:"backup"`
+		}
+		err := maml.Unmarshal([]byte(`{}`), &v)
+		require.EqualError(t, err, `maml: missing required field "host" in type maml_test.requiredServer (server at line 1, column 1)`)
+
+		// An absent pointer stays nil, so its fields are not required.
+		require.NoError(t, maml.Unmarshal([]byte(`{server: {host: "a", port: 1}}`), &v))
+		require.Nil(t, v.Backup)
+	})
+
+	t.Run("Defaults do not satisfy required fields", func(t *testing.T) {
+		var v struct {
+			Port int `maml:"port,required,default=80"`
+		}
+		err := maml.Unmarshal([]byte(`{}`), &v)
+		require.ErrorAs(t, err, new(*maml.MissingFieldError))
+	})
+
+	t.Run("All errors", func(t *testing.T) {
+		var v requiredServer
+		err := maml.Unmarshal([]byte(`{name: "x"}`), &v, maml.AllErrors())
+		var errs maml.DecodeErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 2)
+		require.EqualError(t, errs[0], `maml: missing required field "host" in type maml_test.requiredServer (at line 1, column 1)`)
+	})
+}
+
+type validatedRange struct {
+	Min, Max int
+}
+
+var errRange = errors.New("min must not exceed max")
+
+func (r *validatedRange) ValidateMAML() error {
+	if r.Min > r.Max {
+		return errRange
+	}
+	return nil
+}
+
+type defaultedRange struct {
+	Min int
+	Max int `default:"10"`
+}
+
+func (r *defaultedRange) ValidateMAML() error {
+	if r.Min > r.Max {
+		return errRange
+	}
+	return nil
+}
+
+type validatedOwner struct {
+	Name string
+}
+
+var errNoOwner = errors.New("owner has no name")
+
+func (o *validatedOwner) ValidateMAML() error {
+	if o.Name == "" {
+		return errNoOwner
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		var v validatedRange
+		require.NoError(t, maml.Unmarshal([]byte(`{Min: 1, Max: 2}`), &v))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var v struct {
+			Ranges map[string]validatedRange
+		}
+		err := maml.Unmarshal([]byte(`{Ranges: {cpu: {Min: 3, Max: 2}}}`), &v)
+		var verr *maml.ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.ErrorIs(t, err, errRange)
+		require.Equal(t, "Ranges.cpu", verr.Path)
+		require.EqualError(t, err, "maml: invalid maml_test.validatedRange: min must not exceed max (Ranges.cpu at line 1, column 16)")
+	})
+
+	t.Run("Missing nested struct", func(t *testing.T) {
+		var v struct {
+			Owner validatedOwner `maml:"owner"`
+		}
+		err := maml.Unmarshal([]byte(`{}`), &v)
+		var verr *maml.ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.ErrorIs(t, err, errNoOwner)
+		require.Equal(t, "owner", verr.Path)
+	})
+
+	t.Run("Runs after defaults", func(t *testing.T) {
+		var v defaultedRange
+		require.NoError(t, maml.Unmarshal([]byte(`{Min: 5}`), &v))
+		require.Equal(t, 10, v.Max)
+	})
+}
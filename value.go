@@ -0,0 +1,487 @@
+package maml
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/KimNorgaard/go-maml/token"
+)
+
+// Kind is the kind of a Value.
+type Kind int
+
+// The kinds of Value. The zero Kind is NullKind.
+const (
+	NullKind Kind = iota
+	ObjectKind
+	ArrayKind
+	StringKind
+	IntKind
+	FloatKind
+	BoolKind
+)
+
+var kindNames = [...]string{
+	NullKind:   "null",
+	ObjectKind: "object",
+	ArrayKind:  "array",
+	StringKind: "string",
+	IntKind:    "integer",
+	FloatKind:  "float",
+	BoolKind:   "boolean",
+}
+
+// String returns the name of the kind, as used in error messages.
+func (k Kind) String() string {
+	if k >= 0 && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Value is a MAML value of any kind, for working with documents whose
+// structure is not known in advance. Unlike map[string]any, a Value keeps the
+// order of object keys, integers of any size, the literal text of numbers and
+// whether a key was written as a quoted string, so that a document decoded
+// into a Value encodes back to the same data.
+//
+// The zero Value is null. Values are built with the constructors NewString,
+// NewInt, NewBigInt, NewFloat, NewBool, NewArray, NewObject and NewNull, or by
+// decoding into a Value. A Value shares its arrays and objects with copies of
+// it; use Clone to get an independent copy before modifying one.
+//
+// Comments are not part of a Value. To edit a file without losing them,
+// decode it into a Value and write the result back with MarshalInto.
+type Value struct {
+	kind    Kind
+	str     string   // the string, or the literal text of a number
+	integer int64    // the value of an Int, if big is nil
+	big     *big.Int // the value of an Int outside the int64 range
+	float   float64
+	boolean bool
+	array   []Value
+	object  []Member
+}
+
+// Member is a key and its value in an object Value.
+type Member struct {
+	Key   string
+	Value Value
+
+	// Quoted reports whether the key is written as a quoted string even if
+	// it could be written bare. It is set when decoding.
+	Quoted bool
+}
+
+// NewNull returns a null Value.
+func NewNull() Value { return Value{} }
+
+// NewString returns a string Value.
+func NewString(s string) Value { return Value{kind: StringKind, str: s} }
+
+// NewInt returns an integer Value.
+func NewInt(i int64) Value { return Value{kind: IntKind, integer: i} }
+
+// NewBigInt returns an integer Value for i, which may be outside the range of
+// an int64.
+func NewBigInt(i *big.Int) Value {
+	if i.IsInt64() {
+		return NewInt(i.Int64())
+	}
+	return Value{kind: IntKind, big: new(big.Int).Set(i)}
+}
+
+// NewFloat returns a float Value.
+func NewFloat(f float64) Value { return Value{kind: FloatKind, float: f} }
+
+// NewBool returns a boolean Value.
+func NewBool(b bool) Value { return Value{kind: BoolKind, boolean: b} }
+
+// NewArray returns an array Value holding elems.
+func NewArray(elems ...Value) Value {
+	return Value{kind: ArrayKind, array: append([]Value{}, elems...)}
+}
+
+// NewObject returns an object Value holding members, in order. If a key
+// occurs more than once, the last value is used, at the position of the first.
+func NewObject(members ...Member) Value {
+	v := Value{kind: ObjectKind, object: make([]Member, 0, len(members))}
+	for _, m := range members {
+		if i := v.indexOf(m.Key); i >= 0 {
+			v.object[i].Value = m.Value
+			continue
+		}
+		v.object = append(v.object, m)
+	}
+	return v
+}
+
+// Kind returns the kind of v.
+func (v Value) Kind() Kind { return v.kind }
+
+// IsNull reports whether v is null.
+func (v Value) IsNull() bool { return v.kind == NullKind }
+
+// AsString returns the string of a string Value.
+func (v Value) AsString() (string, bool) {
+	return v.str, v.kind == StringKind
+}
+
+// AsInt returns the value of an integer Value. It fails if the integer does
+// not fit in an int64.
+func (v Value) AsInt() (int64, bool) {
+	return v.integer, v.kind == IntKind && v.big == nil
+}
+
+// AsBigInt returns the value of an integer Value as a new big.Int.
+func (v Value) AsBigInt() (*big.Int, bool) {
+	switch {
+	case v.kind != IntKind:
+		return nil, false
+	case v.big != nil:
+		return new(big.Int).Set(v.big), true
+	default:
+		return big.NewInt(v.integer), true
+	}
+}
+
+// AsFloat returns the value of a float Value, or of an integer Value
+// converted to a float64.
+func (v Value) AsFloat() (float64, bool) {
+	switch {
+	case v.kind == FloatKind:
+		return v.float, true
+	case v.kind == IntKind && v.big != nil:
+		f, _ := new(big.Float).SetInt(v.big).Float64()
+		return f, true
+	case v.kind == IntKind:
+		return float64(v.integer), true
+	default:
+		return 0, false
+	}
+}
+
+// AsBool returns the value of a boolean Value.
+func (v Value) AsBool() (bool, bool) {
+	return v.boolean, v.kind == BoolKind
+}
+
+// AsArray returns the elements of an array Value. The slice is shared with v.
+func (v Value) AsArray() ([]Value, bool) {
+	return v.array, v.kind == ArrayKind
+}
+
+// AsObject returns the members of an object Value in order. The slice is
+// shared with v.
+func (v Value) AsObject() ([]Member, bool) {
+	return v.object, v.kind == ObjectKind
+}
+
+// Len returns the number of elements of an array or members of an object,
+// and 0 for other kinds.
+func (v Value) Len() int {
+	return len(v.array) + len(v.object)
+}
+
+// Index returns the element at index i of an array Value.
+func (v Value) Index(i int) (Value, bool) {
+	if v.kind != ArrayKind || i < 0 || i >= len(v.array) {
+		return Value{}, false
+	}
+	return v.array[i], true
+}
+
+// Get returns the value of key in an object Value.
+func (v Value) Get(key string) (Value, bool) {
+	if i := v.indexOf(key); i >= 0 {
+		return v.object[i].Value, true
+	}
+	return Value{}, false
+}
+
+// Keys returns the keys of an object Value in order.
+func (v Value) Keys() []string {
+	keys := make([]string, len(v.object))
+	for i, m := range v.object {
+		keys[i] = m.Key
+	}
+	return keys
+}
+
+// Lookup returns the value at path, in the syntax of ast.ParsePath, e.g.
+// `server.ports[0]`. The empty path returns v itself. Errors wrap
+// ast.ErrPathNotFound or ast.ErrInvalidPath.
+func (v Value) Lookup(path string) (Value, error) {
+	parsed, err := ast.ParsePath(path)
+	if err != nil {
+		return Value{}, fmt.Errorf("maml: %w", err)
+	}
+	cur := v
+	for i, seg := range parsed {
+		var next Value
+		var ok bool
+		switch {
+		case seg.IsIndex && cur.kind == ArrayKind:
+			next, ok = cur.Index(seg.Index)
+		case !seg.IsIndex && cur.kind == ObjectKind:
+			next, ok = cur.Get(seg.Key)
+		default:
+			return Value{}, fmt.Errorf("maml: %s: %w: %s value", parsed[:i+1], ast.ErrInvalidPath, cur.kind)
+		}
+		if !ok {
+			return Value{}, fmt.Errorf("maml: %s: %w", parsed[:i+1], ast.ErrPathNotFound)
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// Set sets the value of key in an object Value. A new key is appended after
+// the existing ones; an existing key keeps its position. Setting a key of a
+// null Value turns it into an object.
+func (v *Value) Set(key string, x Value) error {
+	switch v.kind {
+	case NullKind:
+		*v = Value{kind: ObjectKind}
+	case ObjectKind:
+	default:
+		return fmt.Errorf("maml: cannot set key %q of %s value", key, v.kind)
+	}
+	if i := v.indexOf(key); i >= 0 {
+		v.object[i].Value = x
+		return nil
+	}
+	v.object = append(v.object, Member{Key: key, Value: x})
+	return nil
+}
+
+// Delete removes key from an object Value and reports whether it was
+// present.
+func (v *Value) Delete(key string) bool {
+	i := v.indexOf(key)
+	if i < 0 {
+		return false
+	}
+	v.object = slices.Delete(v.object, i, i+1)
+	return true
+}
+
+// Append adds elems to the end of an array Value. Appending to a null Value
+// turns it into an array.
+func (v *Value) Append(elems ...Value) error {
+	switch v.kind {
+	case NullKind:
+		*v = Value{kind: ArrayKind}
+	case ArrayKind:
+	default:
+		return fmt.Errorf("maml: cannot append to %s value", v.kind)
+	}
+	v.array = append(v.array, elems...)
+	return nil
+}
+
+// indexOf returns the position of key in an object Value, or -1.
+func (v Value) indexOf(key string) int {
+	return slices.IndexFunc(v.object, func(m Member) bool { return m.Key == key })
+}
+
+// Equal reports whether v and x hold the same data. Objects are equal if they
+// have the same keys with equal values, in any order, whether the keys are
+// quoted or not. Integers and floats are never equal to each other.
+func (v Value) Equal(x Value) bool {
+	if v.kind != x.kind {
+		return false
+	}
+	switch v.kind {
+	case NullKind:
+		return true
+	case StringKind:
+		return v.str == x.str
+	case IntKind:
+		if v.big == nil && x.big == nil {
+			return v.integer == x.integer
+		}
+		a, _ := v.AsBigInt()
+		b, _ := x.AsBigInt()
+		return a.Cmp(b) == 0
+	case FloatKind:
+		return v.float == x.float
+	case BoolKind:
+		return v.boolean == x.boolean
+	case ArrayKind:
+		return slices.EqualFunc(v.array, x.array, Value.Equal)
+	case ObjectKind:
+		if len(v.object) != len(x.object) {
+			return false
+		}
+		for _, m := range v.object {
+			other, ok := x.Get(m.Key)
+			if !ok || !m.Value.Equal(other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Clone returns a deep copy of v.
+func (v Value) Clone() Value {
+	c := v
+	if v.big != nil {
+		c.big = new(big.Int).Set(v.big)
+	}
+	if v.array != nil {
+		c.array = make([]Value, len(v.array))
+		for i, el := range v.array {
+			c.array[i] = el.Clone()
+		}
+	}
+	if v.object != nil {
+		c.object = make([]Member, len(v.object))
+		for i, m := range v.object {
+			c.object[i] = m
+			c.object[i].Value = m.Value.Clone()
+		}
+	}
+	return c
+}
+
+// String returns the compact MAML encoding of v.
+func (v Value) String() string {
+	b, _ := Marshal(v, Indent(0))
+	return string(b)
+}
+
+var valueType = reflect.TypeFor[Value]()
+
+// node returns the AST of v.
+func (v Value) node() ast.Expression {
+	switch v.kind {
+	case StringKind:
+		return ast.NewString(v.str)
+	case IntKind:
+		var n *ast.IntegerLiteral
+		if v.big != nil {
+			n = ast.NewBigInteger(v.big)
+		} else {
+			n = ast.NewInteger(v.integer)
+		}
+		if v.str != "" {
+			n.Token.Literal = v.str
+		}
+		return n
+	case FloatKind:
+		if v.str != "" {
+			return &ast.FloatLiteral{Token: token.Token{Type: token.FLOAT, Literal: v.str}, Value: v.float}
+		}
+		return ast.NewFloat(v.float)
+	case BoolKind:
+		return ast.NewBoolean(v.boolean)
+	case ArrayKind:
+		elems := make([]ast.Expression, len(v.array))
+		for i, el := range v.array {
+			elems[i] = el.node()
+		}
+		return ast.NewArray(elems...)
+	case ObjectKind:
+		pairs := make([]*ast.KeyValueExpression, len(v.object))
+		for i, m := range v.object {
+			pairs[i] = ast.NewKeyValue(m.Key, m.Value.node())
+			if m.Quoted {
+				pairs[i].Key = ast.NewString(m.Key)
+			}
+		}
+		return ast.NewObject(pairs...)
+	default:
+		return ast.NewNull()
+	}
+}
+
+// marshalDynamic encodes a Value. Its keys are written in order, regardless
+// of the key order options.
+func (e *encodeState) marshalDynamic(v reflect.Value) (ast.Node, error) {
+	if !v.CanInterface() {
+		return nil, errors.New("maml: cannot marshal unexported Value")
+	}
+	value, _ := v.Interface().(Value)
+	return value.node(), nil
+}
+
+// mapDynamic decodes any MAML value into a Value.
+func (ds *decodeState) mapDynamic(expr ast.Expression, rv reflect.Value) error {
+	value, err := ds.dynamicValue(expr)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.ValueOf(value))
+	return nil
+}
+
+// dynamicValue returns the Value of expr. The literal text of numbers is
+// kept, so that they are written back as they were.
+func (ds *decodeState) dynamicValue(expr ast.Expression) (Value, error) {
+	ds.depth--
+	if ds.depth <= 0 {
+		return Value{}, fmt.Errorf("maml: reached max recursion depth")
+	}
+	defer func() { ds.depth++ }()
+
+	switch n := expr.(type) {
+	case *ast.NullLiteral:
+		return NewNull(), nil
+	case *ast.Identifier:
+		return NewString(n.Value), nil
+	case *ast.StringLiteral:
+		s, err := ds.stringValue(n)
+		if err != nil {
+			return Value{}, ds.report(err)
+		}
+		return NewString(s), nil
+	case *ast.IntegerLiteral:
+		v := Value{kind: IntKind, integer: n.Value, str: n.Token.Literal}
+		if n.Big != nil {
+			v.big = new(big.Int).Set(n.Big)
+		}
+		return v, nil
+	case *ast.FloatLiteral:
+		return Value{kind: FloatKind, float: n.Value, str: n.Token.Literal}, nil
+	case *ast.BooleanLiteral:
+		return NewBool(n.Value), nil
+	case *ast.ArrayLiteral:
+		v := Value{kind: ArrayKind, array: make([]Value, 0, len(n.Elements))}
+		for i, el := range n.Elements {
+			ds.pushIndex(i)
+			elem, err := ds.dynamicValue(el.Value)
+			ds.pop()
+			if err != nil {
+				return Value{}, err
+			}
+			v.array = append(v.array, elem)
+		}
+		return v, nil
+	case *ast.ObjectLiteral:
+		v := Value{kind: ObjectKind, object: make([]Member, 0, len(n.Pairs))}
+		for _, pair := range n.Pairs {
+			key, err := resolveMapKey(pair.Key)
+			if err != nil {
+				return Value{}, err
+			}
+			ds.pushKey(key)
+			member, err := ds.dynamicValue(pair.Value)
+			ds.pop()
+			if err != nil {
+				return Value{}, err
+			}
+			_, quoted := pair.Key.(*ast.StringLiteral)
+			v.object = append(v.object, Member{Key: key, Value: member, Quoted: quoted && ast.IsBareKey(key)})
+		}
+		return v, nil
+	default:
+		return Value{}, fmt.Errorf("maml: mapping for AST node type %T not yet implemented", expr)
+	}
+}
@@ -0,0 +1,222 @@
+package maml_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KimNorgaard/go-maml"
+	"github.com/KimNorgaard/go-maml/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValue_RoundTrip(t *testing.T) {
+	src := `{
+  zone: "eu-west"
+  "name": "api"
+  replicas: 3
+  ratio: 1.50
+  huge: 123456789012345678901234567890
+  debug: false
+  owner: null
+  ports: [
+    {
+      target: 80
+      name: "http"
+    }
+  ]
+}`
+
+	var v maml.Value
+	require.NoError(t, maml.Unmarshal([]byte(src), &v))
+	require.Equal(t, maml.ObjectKind, v.Kind())
+	require.Equal(t, []string{"zone", "name", "replicas", "ratio", "huge", "debug", "owner", "ports"}, v.Keys())
+
+	out, err := maml.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, src, string(out))
+
+	out, err = maml.Marshal(v, maml.SortKeys())
+	require.NoError(t, err)
+	require.Equal(t, src, string(out), "a Value ignores the key order options")
+}
+
+func TestValue_Accessors(t *testing.T) {
+	var v maml.Value
+	require.NoError(t, maml.Unmarshal([]byte(`{
+  zone: eu_west
+  "name": "api"
+  replicas: 3
+  ratio: 1.5
+  huge: 123456789012345678901234567890
+  debug: true
+  ports: [80, 443]
+}`), &v))
+
+	zone, _ := v.Get("zone")
+	s, ok := zone.AsString()
+	require.True(t, ok)
+	require.Equal(t, "eu_west", s, "identifiers decode as strings")
+
+	name, _ := v.Get("name")
+	_, ok = name.AsInt()
+	require.False(t, ok)
+
+	replicas, _ := v.Get("replicas")
+	i, ok := replicas.AsInt()
+	require.True(t, ok)
+	require.Equal(t, int64(3), i)
+	f, ok := replicas.AsFloat()
+	require.True(t, ok)
+	require.Equal(t, 3.0, f)
+
+	huge, _ := v.Get("huge")
+	_, ok = huge.AsInt()
+	require.False(t, ok, "the integer does not fit in an int64")
+	b, ok := huge.AsBigInt()
+	require.True(t, ok)
+	require.Equal(t, "123456789012345678901234567890", b.String())
+
+	ratio, _ := v.Get("ratio")
+	f, ok = ratio.AsFloat()
+	require.True(t, ok)
+	require.Equal(t, 1.5, f)
+
+	debug, _ := v.Get("debug")
+	d, ok := debug.AsBool()
+	require.True(t, ok)
+	require.True(t, d)
+
+	ports, _ := v.Get("ports")
+	elems, ok := ports.AsArray()
+	require.True(t, ok)
+	require.Len(t, elems, 2)
+	require.Equal(t, 2, ports.Len())
+	_, ok = ports.Index(2)
+	require.False(t, ok)
+
+	members, ok := v.AsObject()
+	require.True(t, ok)
+	require.Equal(t, "zone", members[0].Key)
+	require.False(t, members[0].Quoted)
+	require.True(t, members[1].Quoted)
+	require.Equal(t, 7, v.Len())
+	_, ok = v.Get("missing")
+	require.False(t, ok)
+	require.True(t, maml.Value{}.IsNull())
+}
+
+func TestValue_Lookup(t *testing.T) {
+	v := maml.NewObject(
+		maml.Member{Key: "server", Value: maml.NewObject(
+			maml.Member{Key: "ports", Value: maml.NewArray(maml.NewInt(80), maml.NewInt(443))},
+		)},
+		maml.Member{Key: "app.kubernetes.io/name", Value: maml.NewString("api")},
+	)
+
+	port, err := v.Lookup("server.ports[1]")
+	require.NoError(t, err)
+	require.True(t, port.Equal(maml.NewInt(443)))
+
+	name, err := v.Lookup(`["app.kubernetes.io/name"]`)
+	require.NoError(t, err)
+	require.True(t, name.Equal(maml.NewString("api")))
+
+	root, err := v.Lookup("")
+	require.NoError(t, err)
+	require.True(t, root.Equal(v))
+
+	_, err = v.Lookup("server.ports[2]")
+	require.ErrorIs(t, err, ast.ErrPathNotFound)
+	require.EqualError(t, err, "maml: server.ports[2]: path not found")
+
+	_, err = v.Lookup("server.ports.first")
+	require.ErrorIs(t, err, ast.ErrInvalidPath)
+	require.EqualError(t, err, "maml: server.ports.first: invalid path: array value")
+
+	_, err = v.Lookup("server[")
+	require.ErrorIs(t, err, ast.ErrInvalidPath)
+}
+
+func TestValue_Edit(t *testing.T) {
+	var v maml.Value
+	require.NoError(t, v.Set("name", maml.NewString("api")))
+	require.NoError(t, v.Set("tags", maml.NewArray()))
+	require.NoError(t, v.Set("name", maml.NewString("web")))
+
+	tags, _ := v.Get("tags")
+	require.NoError(t, tags.Append(maml.NewString("a"), maml.NewString("b")))
+	require.NoError(t, v.Set("tags", tags))
+	require.True(t, v.Delete("tags"))
+	require.False(t, v.Delete("tags"))
+	require.NoError(t, v.Set("tags", tags))
+	require.NoError(t, v.Set("size", maml.NewBigInt(new(big.Int).Lsh(big.NewInt(1), 70))))
+	require.NoError(t, v.Set("ratio", maml.NewFloat(2)))
+	require.NoError(t, v.Set("on", maml.NewBool(true)))
+	require.NoError(t, v.Set("off", maml.NewNull()))
+
+	require.Equal(t, `{name:"web",tags:["a","b"],size:1180591620717411303424,ratio:2.0,on:true,off:null}`, v.String())
+
+	var list maml.Value
+	require.NoError(t, list.Append(maml.NewInt(1)))
+	require.Equal(t, `[1]`, list.String())
+
+	require.EqualError(t, list.Set("a", maml.NewInt(1)), `maml: cannot set key "a" of array value`)
+	require.EqualError(t, v.Append(maml.NewInt(1)), "maml: cannot append to object value")
+}
+
+func TestValue_EqualClone(t *testing.T) {
+	a := maml.NewObject(
+		maml.Member{Key: "a", Value: maml.NewInt(1)},
+		maml.Member{Key: "b", Value: maml.NewArray(maml.NewString("x"), maml.NewNull())},
+	)
+	b := maml.NewObject(
+		maml.Member{Key: "b", Value: maml.NewArray(maml.NewString("x"), maml.NewNull()), Quoted: true},
+		maml.Member{Key: "a", Value: maml.NewInt(1)},
+	)
+	require.True(t, a.Equal(b), "key order and quoting do not matter")
+	require.False(t, maml.NewInt(1).Equal(maml.NewFloat(1)))
+	require.True(t, maml.NewBigInt(big.NewInt(5)).Equal(maml.NewInt(5)))
+	require.False(t, maml.NewArray(maml.NewInt(1)).Equal(maml.NewArray(maml.NewInt(1), maml.NewInt(2))))
+	require.False(t, a.Equal(maml.NewObject(maml.Member{Key: "a", Value: maml.NewInt(1)})))
+
+	c := a.Clone()
+	require.True(t, c.Equal(a))
+	list, _ := c.Get("b")
+	elems, _ := list.AsArray()
+	elems[0] = maml.NewString("changed")
+	require.NoError(t, c.Set("a", maml.NewInt(2)))
+	require.Equal(t, `{a:1,b:["x",null]}`, a.String(), "the clone does not share data")
+	require.Equal(t, `{a:2,b:["changed",null]}`, c.String())
+}
+
+func TestValue_Decode(t *testing.T) {
+	t.Run("Struct field and variables", func(t *testing.T) {
+		var v struct {
+			Extra maml.Value  `maml:"extra"`
+			Opt   *maml.Value `maml:"opt"`
+		}
+		lookup := func(string) (string, bool) { return "prod", true }
+		require.NoError(t, maml.Unmarshal([]byte(`{extra: {env: "${ENV}"}, opt: null}`), &v, maml.ExpandVariables(lookup)))
+		require.Equal(t, `{env:"prod"}`, v.Extra.String())
+		require.Nil(t, v.Opt)
+	})
+
+	t.Run("Max depth", func(t *testing.T) {
+		var v maml.Value
+		err := maml.Unmarshal([]byte(`[[[[1]]]]`), &v, maml.MaxDepth(3))
+		require.EqualError(t, err, "maml: reached max recursion depth")
+	})
+
+	t.Run("MarshalInto", func(t *testing.T) {
+		src := "{\n  # The name\n  name: \"api\"\n}"
+		doc, err := maml.Parse([]byte(src))
+		require.NoError(t, err)
+		var v maml.Value
+		require.NoError(t, maml.Unmarshal([]byte(src), &v))
+		require.NoError(t, v.Set("name", maml.NewString("web")))
+		require.NoError(t, maml.MarshalInto(doc, v))
+		out, err := maml.Marshal(doc)
+		require.NoError(t, err)
+		require.Equal(t, "{\n  # The name\n  name: \"web\"\n}", string(out))
+	})
+}